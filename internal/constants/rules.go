@@ -1,6 +1,8 @@
 package constants
 
 import (
+	"time"
+
 	"github.com/kerimovok/go-pkg-utils/config"
 	"github.com/kerimovok/go-pkg-utils/validator"
 )
@@ -71,6 +73,12 @@ var EnvValidationRules = []validator.ValidationRule{
 		Rule:     config.IsValidNonEmptyString,
 		Message:  "SMTP password is required",
 	},
+	{
+		Variable: "SMTP_TIMEOUT",
+		Default:  "60",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "SMTP_TIMEOUT must be a positive number (seconds)",
+	},
 
 	// SMTP From validation
 	{
@@ -109,6 +117,100 @@ var EnvValidationRules = []validator.ValidationRule{
 		Message:  "QUEUE_MAX_RETRY_DELAY must be a valid number (seconds)",
 	},
 
+	// Delivery worker pool configuration
+	{
+		Variable: "MAIL_WORKERS",
+		Default:  "4",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_WORKERS must be a positive number",
+	},
+	{
+		Variable: "MAIL_WORKER_QUEUE_SIZE",
+		Default:  "1000",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_WORKER_QUEUE_SIZE must be a positive number",
+	},
+	{
+		Variable: "MAIL_RECOVERY_INTERVAL",
+		Default:  "30",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_RECOVERY_INTERVAL must be a positive number (seconds)",
+	},
+	{
+		Variable: "MAIL_SENDING_TIMEOUT",
+		Default:  "600",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_SENDING_TIMEOUT must be a positive number (seconds)",
+	},
+
+	{
+		Variable: "MAIL_SCHEDULER_INTERVAL",
+		Default:  "10",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_SCHEDULER_INTERVAL must be a positive number (seconds)",
+	},
+	{
+		Variable: "MAIL_BATCH_MAX_SIZE",
+		Default:  "1000",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "MAIL_BATCH_MAX_SIZE must be a positive number",
+	},
+
+	// Attachment configuration
+	{
+		Variable: "ATTACHMENT_MAX_FILE_SIZE",
+		Default:  "10485760",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "ATTACHMENT_MAX_FILE_SIZE must be a positive number (bytes)",
+	},
+	{
+		Variable: "ATTACHMENT_MAX_TOTAL_SIZE",
+		Default:  "26214400",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "ATTACHMENT_MAX_TOTAL_SIZE must be a positive number (bytes)",
+	},
+	{
+		Variable: "ATTACHMENT_STORAGE",
+		Default:  "local",
+		Rule:     func(v string) bool { return v == "local" || v == "s3" },
+		Message:  "ATTACHMENT_STORAGE must be either 'local' or 's3'",
+	},
+	{
+		Variable: "ATTACHMENT_LOCAL_DIR",
+		Default:  "attachments",
+		Rule:     config.IsValidNonEmptyString,
+		Message:  "ATTACHMENT_LOCAL_DIR must be a directory path",
+	},
+
+	// Template configuration
+	{
+		Variable: "TEMPLATE_STRICT_MODE",
+		Default:  "false",
+		Rule:     func(v string) bool { return v == "true" || v == "false" },
+		Message:  "TEMPLATE_STRICT_MODE must be either 'true' or 'false'",
+	},
+	{
+		Variable: "TEMPLATE_INLINE_CSS",
+		Default:  "false",
+		Rule:     func(v string) bool { return v == "true" || v == "false" },
+		Message:  "TEMPLATE_INLINE_CSS must be either 'true' or 'false'",
+	},
+	{
+		Variable: "TEMPLATE_TIMEZONE",
+		Default:  "UTC",
+		Rule: func(v string) bool {
+			_, err := time.LoadLocation(v)
+			return err == nil
+		},
+		Message: "TEMPLATE_TIMEZONE must be an IANA timezone name, e.g. 'Europe/Berlin'",
+	},
+	{
+		Variable: "TEMPLATE_CACHE_WATCH_INTERVAL",
+		Default:  "2",
+		Rule:     config.IsValidPositiveInteger,
+		Message:  "TEMPLATE_CACHE_WATCH_INTERVAL must be a positive number (seconds)",
+	},
+
 	// RabbitMQ validation (only required when EMAIL_PROCESSING_MODE includes queue processing)
 	{
 		Variable: "RABBITMQ_HOST",
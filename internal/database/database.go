@@ -32,11 +32,65 @@ func ConnectDB() error {
 	}
 
 	// Use go-pkg-database to open connection and auto-migrate
-	db, err := sql.OpenGorm(gormConfig, &models.Mail{}, &models.Attachment{})
+	db, err := sql.OpenGorm(gormConfig, &models.Mail{}, &models.Attachment{}, &models.MailBatch{}, &models.MailRecipient{}, &models.AttachmentFile{}, &models.Template{}, &models.TemplateVersion{})
 	if err != nil {
 		return err
 	}
 
 	DB = db.DB
+
+	if err := migrateLegacyRecipients(); err != nil {
+		return err
+	}
+
+	if err := createIndexes(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createIndexes creates composite indexes that cannot be declared through struct tags
+// because they span fields of the embedded sql.BaseModel
+func createIndexes() error {
+	statements := []string{
+		// Keyset pagination for GET /api/v1/mails
+		`CREATE INDEX IF NOT EXISTS idx_mails_created_at_id ON mails (created_at, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_mails_status_created_at_id ON mails (status, created_at, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_mails_template_created_at_id ON mails (template, created_at, id)`,
+		// Recipient filter, matched with the jsonb containment operator
+		`CREATE INDEX IF NOT EXISTS idx_mails_to_addresses ON mails USING GIN (to_addresses)`,
+		`CREATE INDEX IF NOT EXISTS idx_mails_cc_addresses ON mails USING GIN (cc_addresses)`,
+		`CREATE INDEX IF NOT EXISTS idx_mails_bcc_addresses ON mails USING GIN (bcc_addresses)`,
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// migrateLegacyRecipients moves the single-address "to" column into the to_addresses list
+// and drops it once copied. Pending mails of the previous schema are marked failed, since they may
+// have been sent before the status was saved and delivery recovery would send them again.
+func migrateLegacyRecipients() error {
+	if !DB.Migrator().HasColumn("mails", "to") {
+		return nil
+	}
+
+	if err := DB.Exec(`UPDATE mails SET to_addresses = jsonb_build_array("to") WHERE to_addresses IS NULL AND "to" IS NOT NULL AND "to" <> ''`).Error; err != nil {
+		return err
+	}
+
+	if err := DB.Model(&models.Mail{}).Where("status = ?", models.MailStatusPending).Updates(map[string]interface{}{
+		"status": models.MailStatusFailed,
+		"error":  "delivery was interrupted before the upgrade, the outcome is unknown",
+	}).Error; err != nil {
+		return err
+	}
+
+	return DB.Migrator().DropColumn("mails", "to")
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mailer-api/internal/requests"
+	"mailer-api/internal/services"
+	"mime"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// UploadAttachment streams the "file" part of a multipart/form-data request into attachment storage
+func UploadAttachment(c *fiber.Ctx) error {
+	mediaType, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || mediaType != fiber.MIMEMultipartForm || params["boundary"] == "" {
+		response := httpx.UnsupportedMediaType("Request must be multipart/form-data")
+		return httpx.SendResponse(c, response)
+	}
+
+	// Read the body as a stream so large files are never held in memory
+	var body io.Reader = c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			response := httpx.BadRequest("Invalid multipart body", err)
+			return httpx.SendResponse(c, response)
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			continue
+		}
+
+		file, err := services.StoreAttachment(part.FileName(), part.Header.Get(fiber.HeaderContentType), part)
+		if err != nil {
+			if errors.IsCode(err, "ATTACHMENT_TOO_LARGE") {
+				response := httpx.PayloadTooLarge(fmt.Sprintf("Attachment exceeds the maximum file size of %d bytes", requests.MaxAttachmentSize()))
+				return httpx.SendResponse(c, response)
+			}
+			log.Printf("failed to store attachment: %v", err)
+			response := httpx.InternalServerError("Failed to store attachment", err)
+			return httpx.SendResponse(c, response)
+		}
+
+		response := httpx.Created("Attachment uploaded successfully", file)
+		return httpx.SendResponse(c, response)
+	}
+
+	return sendValidationErrors(c, validator.ValidationErrors{{Field: "file", Message: "field is required", Tag: "required"}})
+}
+
+func GetAttachmentByID(c *fiber.Ctx) error {
+	file, err := services.GetAttachment(c.Params("id"))
+	if err != nil {
+		response := httpx.NotFound("Attachment not found")
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Attachment fetched successfully", file)
+	return httpx.SendResponse(c, response)
+}
+
+// DownloadAttachment streams the attachment content
+func DownloadAttachment(c *fiber.Ctx) error {
+	file, err := services.GetAttachment(c.Params("id"))
+	if err != nil {
+		response := httpx.NotFound("Attachment not found")
+		return httpx.SendResponse(c, response)
+	}
+
+	content, err := services.OpenAttachment(file)
+	if err != nil {
+		log.Printf("failed to open attachment: %v", err)
+		response := httpx.InternalServerError("Failed to open attachment", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	c.Attachment(file.Filename)
+	c.Set(fiber.HeaderContentType, file.ContentType)
+	return c.SendStream(content, int(file.Size))
+}
+
+func DeleteAttachment(c *fiber.Ctx) error {
+	if err := services.DeleteAttachment(c.Params("id")); err != nil {
+		switch {
+		case errors.IsCode(err, "ATTACHMENT_NOT_FOUND"):
+			response := httpx.NotFound("Attachment not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "ATTACHMENT_IN_USE"):
+			response := httpx.Conflict("Attachment is referenced by mails that have not been sent yet", err)
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to delete attachment: %v", err)
+		response := httpx.InternalServerError("Failed to delete attachment", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Attachment deleted successfully", nil)
+	return httpx.SendResponse(c, response)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"mailer-api/internal/database"
 	"mailer-api/internal/models"
@@ -8,6 +9,8 @@ import (
 	"mailer-api/internal/services"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/errors"
 	"github.com/kerimovok/go-pkg-utils/httpx"
 	"github.com/kerimovok/go-pkg-utils/validator"
 )
@@ -19,35 +22,78 @@ func SendMail(c *fiber.Ctx) error {
 		return httpx.SendResponse(c, response)
 	}
 
-	validationErrors := validator.ValidateStruct(&input)
+	validationErrors := input.Validate()
 	if validationErrors.HasErrors() {
-		// Convert validator.ValidationErrors to []httpx.ValidationError
-		httpxErrors := make([]httpx.ValidationError, len(validationErrors))
-		for i, err := range validationErrors {
-			httpxErrors[i] = httpx.ValidationError{
-				Field:   err.Field,
-				Message: err.Message,
-			}
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	// Resolve the template once to check the subject and data against it
+	check, validationErrors, err := services.ResolveTemplateCheck(input.Template, input.Locale)
+	if err != nil {
+		log.Printf("failed to load template: %v", err)
+		response := httpx.InternalServerError("Failed to load template", err)
+		return httpx.SendResponse(c, response)
+	}
+	validationErrors = append(validationErrors, services.ValidateTenant(input.Tenant)...)
+	if check != nil {
+		// Mails without a subject need a template that provides one
+		validationErrors = append(validationErrors, check.ValidateSubject(input.Subject)...)
+
+		// Check the data against the template's schema, if it declares one
+		dataErrors, err := check.ValidateData(input.Data)
+		if err != nil {
+			log.Printf("failed to validate template data: %v", err)
+			response := httpx.InternalServerError("Failed to validate template data", err)
+			return httpx.SendResponse(c, response)
 		}
-		response := httpx.UnprocessableEntityWithValidation("Validation failed", httpxErrors)
-		return httpx.SendValidationResponse(c, response)
+		validationErrors = append(validationErrors, dataErrors...)
+	}
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	// Keys are namespaced per source so an HTTP client cannot collide with an AMQP message ID
+	if key := c.Get("Idempotency-Key"); key != "" {
+		input.IdempotencyKey = "http:" + key
 	}
 
-	// Use unified email processing
-	mail, err := services.ProcessEmailRequest(input.To, input.Subject, input.Template, input.Data, input.Attachments)
+	// Persist the mail and leave delivery to the worker pool
+	mail, created, err := services.QueueEmailRequest(input)
 	if err != nil {
-		log.Printf("failed to process mail: %v", err)
-		response := httpx.InternalServerError("Failed to process mail", err)
+		if errors.IsCode(err, "IDEMPOTENCY_KEY_REUSED") {
+			response := httpx.Conflict("Idempotency key was already used with a different request", err)
+			return httpx.SendResponse(c, response)
+		}
+		if errors.IsCode(err, "ATTACHMENT_NOT_FOUND") {
+			return sendValidationErrors(c, validator.ValidationErrors{{Field: "attachments", Message: "attachment not found"}})
+		}
+		log.Printf("failed to queue mail: %v", err)
+		response := httpx.InternalServerError("Failed to queue mail", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	if !created {
+		response := httpx.OK("Email already accepted", mail)
 		return httpx.SendResponse(c, response)
 	}
 
-	response := httpx.OK("Email processed successfully", mail)
+	response := httpx.Accepted("Email queued for delivery", mail)
 	return httpx.SendResponse(c, response)
 }
 
 func GetMails(c *fiber.Ctx) error {
-	var mails []models.Mail
-	if err := database.DB.Find(&mails).Error; err != nil {
+	var query requests.MailListQuery
+	if err := c.QueryParser(&query); err != nil {
+		response := httpx.BadRequest("Invalid query parameters", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	if validationErrors := query.Validate(); validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	mails, err := services.ListMails(query)
+	if err != nil {
 		log.Printf("failed to fetch mails: %v", err)
 		response := httpx.InternalServerError("Failed to fetch mails", err)
 		return httpx.SendResponse(c, response)
@@ -59,10 +105,158 @@ func GetMails(c *fiber.Ctx) error {
 func GetMailByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var mail models.Mail
-	if err := database.DB.First(&mail, id).Error; err != nil {
+	if err := database.DB.Preload("Attachments.AttachmentFile").Preload("Recipients").Where("id = ?", id).First(&mail).Error; err != nil {
 		response := httpx.NotFound("Mail not found")
 		return httpx.SendResponse(c, response)
 	}
 	response := httpx.OK("Mail fetched successfully", mail)
 	return httpx.SendResponse(c, response)
 }
+
+func SendBatch(c *fiber.Ctx) error {
+	var input requests.BatchMailRequest
+	if err := c.BodyParser(&input); err != nil {
+		response := httpx.BadRequest("Invalid request body", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	maxSize := config.GetEnvInt("MAIL_BATCH_MAX_SIZE", 1000)
+	if validationErrors := input.Validate(maxSize); validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	// Resolve the template once to check the subject and every recipient's data against it
+	check, validationErrors, err := services.ResolveTemplateCheck(input.Template, input.Locale)
+	if err != nil {
+		log.Printf("failed to load template: %v", err)
+		response := httpx.InternalServerError("Failed to load template", err)
+		return httpx.SendResponse(c, response)
+	}
+	validationErrors = append(validationErrors, services.ValidateTenant(input.Tenant)...)
+	if check != nil {
+		// Mails without a subject need a template that provides one
+		validationErrors = append(validationErrors, check.ValidateSubject(input.Subject)...)
+
+		// Check every recipient's data against the template's schema, if it declares one
+		for i, recipient := range input.Recipients {
+			recipientErrs, err := check.ValidateData(recipient.Data)
+			if err != nil {
+				log.Printf("failed to validate template data: %v", err)
+				response := httpx.InternalServerError("Failed to validate template data", err)
+				return httpx.SendResponse(c, response)
+			}
+			for _, recipientErr := range recipientErrs {
+				recipientErr.Field = fmt.Sprintf("recipients[%d].%s", i, recipientErr.Field)
+				validationErrors = append(validationErrors, recipientErr)
+			}
+		}
+	}
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	batch, err := services.CreateBatch(input)
+	if err != nil {
+		if errors.IsCode(err, "ATTACHMENT_NOT_FOUND") {
+			return sendValidationErrors(c, validator.ValidationErrors{{Field: "attachments", Message: "attachment not found"}})
+		}
+		log.Printf("failed to create batch: %v", err)
+		response := httpx.InternalServerError("Failed to create batch", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.Accepted("Batch queued for delivery", batch)
+	return httpx.SendResponse(c, response)
+}
+
+func GetBatchByID(c *fiber.Ctx) error {
+	progress, err := services.GetBatchProgress(c.Params("id"))
+	if err != nil {
+		response := httpx.NotFound("Batch not found")
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Batch fetched successfully", progress)
+	return httpx.SendResponse(c, response)
+}
+
+func ResendMail(c *fiber.Ctx) error {
+	var input requests.ResendMailRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			response := httpx.BadRequest("Invalid request body", err)
+			return httpx.SendResponse(c, response)
+		}
+	}
+
+	if validationErrors := input.Validate(); validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	original, resend, err := services.PrepareResend(c.Params("id"), input)
+	if err != nil {
+		switch {
+		case errors.IsCode(err, "MAIL_NOT_FOUND"):
+			response := httpx.NotFound("Mail not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "MAIL_NOT_FINISHED"):
+			response := httpx.Conflict("Mail is still being processed", err)
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "UNKNOWN_TENANT"):
+			return sendValidationErrors(c, validator.ValidationErrors{{Field: "tenant", Message: "tenant is not configured in the brand configuration"}})
+		}
+		log.Printf("failed to prepare resend: %v", err)
+		response := httpx.InternalServerError("Failed to resend mail", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	// The template may have changed since the original was sent, and the data may have been overridden
+	check, validationErrors, err := services.ResolveTemplateCheck(resend.Template, resend.Locale)
+	if err != nil {
+		log.Printf("failed to load template: %v", err)
+		response := httpx.InternalServerError("Failed to load template", err)
+		return httpx.SendResponse(c, response)
+	}
+	if check != nil {
+		validationErrors = append(validationErrors, check.ValidateSubject(resend.Subject)...)
+		dataErrors, err := check.ValidateData(resend.Data)
+		if err != nil {
+			log.Printf("failed to validate template data: %v", err)
+			response := httpx.InternalServerError("Failed to validate template data", err)
+			return httpx.SendResponse(c, response)
+		}
+		validationErrors = append(validationErrors, dataErrors...)
+	}
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	mail, err := services.ResendMail(original, resend)
+	if err != nil {
+		log.Printf("failed to resend mail: %v", err)
+		response := httpx.InternalServerError("Failed to resend mail", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.Accepted("Email queued for resend", mail)
+	return httpx.SendResponse(c, response)
+}
+
+func CancelMail(c *fiber.Ctx) error {
+	mail, err := services.CancelScheduledMail(c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.IsCode(err, "MAIL_NOT_FOUND"):
+			response := httpx.NotFound("Mail not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "MAIL_NOT_SCHEDULED"):
+			response := httpx.Conflict("Only scheduled mails that have not been sent can be cancelled", err)
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to cancel mail: %v", err)
+		response := httpx.InternalServerError("Failed to cancel mail", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Mail cancelled successfully", mail)
+	return httpx.SendResponse(c, response)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"log"
+	"mailer-api/internal/requests"
+	"mailer-api/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+)
+
+func CreateTemplate(c *fiber.Ctx) error {
+	var input requests.CreateTemplateRequest
+	if err := c.BodyParser(&input); err != nil {
+		response := httpx.BadRequest("Invalid request body", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	validationErrors := input.Validate()
+	validationErrors = append(validationErrors, services.ValidateTemplateSyntax(input.Subject, input.HTMLBody, input.TextBody)...)
+	validationErrors = append(validationErrors, services.ValidateTemplateSchema(input.Schema)...)
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	tmpl, err := services.CreateTemplate(input)
+	if err != nil {
+		if errors.IsCode(err, "TEMPLATE_EXISTS") {
+			response := httpx.Conflict("A template with this name and locale already exists", err)
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to create template: %v", err)
+		response := httpx.InternalServerError("Failed to create template", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.Created("Template created successfully", tmpl)
+	return httpx.SendResponse(c, response)
+}
+
+func GetTemplates(c *fiber.Ctx) error {
+	templates, err := services.ListTemplates()
+	if err != nil {
+		log.Printf("failed to fetch templates: %v", err)
+		response := httpx.InternalServerError("Failed to fetch templates", err)
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Templates fetched successfully", templates)
+	return httpx.SendResponse(c, response)
+}
+
+func GetTemplateByName(c *fiber.Ctx) error {
+	tmpl, err := services.GetTemplate(c.Params("name"), templateLocale(c))
+	if err != nil {
+		if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to fetch template: %v", err)
+		response := httpx.InternalServerError("Failed to fetch template", err)
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Template fetched successfully", tmpl)
+	return httpx.SendResponse(c, response)
+}
+
+// UpdateTemplate stores the body as a new template version, which goes live once it is published
+func UpdateTemplate(c *fiber.Ctx) error {
+	var input requests.UpdateTemplateRequest
+	if err := c.BodyParser(&input); err != nil {
+		response := httpx.BadRequest("Invalid request body", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	validationErrors := input.Validate()
+	validationErrors = append(validationErrors, services.ValidateTemplateSyntax(input.Subject, input.HTMLBody, input.TextBody)...)
+	validationErrors = append(validationErrors, services.ValidateTemplateSchema(input.Schema)...)
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	version, err := services.CreateTemplateVersion(c.Params("name"), templateLocale(c), input)
+	if err != nil {
+		if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to create template version: %v", err)
+		response := httpx.InternalServerError("Failed to create template version", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.Created("Template version created successfully", version)
+	return httpx.SendResponse(c, response)
+}
+
+func GetTemplateVersions(c *fiber.Ctx) error {
+	versions, err := services.ListTemplateVersions(c.Params("name"), templateLocale(c))
+	if err != nil {
+		if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to fetch template versions: %v", err)
+		response := httpx.InternalServerError("Failed to fetch template versions", err)
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Template versions fetched successfully", versions)
+	return httpx.SendResponse(c, response)
+}
+
+func GetTemplateVersion(c *fiber.Ctx) error {
+	number, err := c.ParamsInt("version")
+	if err != nil || number < 1 {
+		response := httpx.NotFound("Template version not found")
+		return httpx.SendResponse(c, response)
+	}
+
+	version, err := services.GetTemplateVersion(c.Params("name"), templateLocale(c), number)
+	if err != nil {
+		switch {
+		case errors.IsCode(err, "TEMPLATE_NOT_FOUND"):
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "TEMPLATE_VERSION_NOT_FOUND"):
+			response := httpx.NotFound("Template version not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to fetch template version: %v", err)
+		response := httpx.InternalServerError("Failed to fetch template version", err)
+		return httpx.SendResponse(c, response)
+	}
+	response := httpx.OK("Template version fetched successfully", version)
+	return httpx.SendResponse(c, response)
+}
+
+// PublishTemplate makes the requested version, or the latest one, the version mails are rendered with
+func PublishTemplate(c *fiber.Ctx) error {
+	var input requests.TemplateVersionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			response := httpx.BadRequest("Invalid request body", err)
+			return httpx.SendResponse(c, response)
+		}
+	}
+
+	if validationErrors := input.Validate(); validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	tmpl, err := services.PublishTemplateVersion(c.Params("name"), templateLocale(c), input.Version)
+	if err != nil {
+		switch {
+		case errors.IsCode(err, "TEMPLATE_NOT_FOUND"):
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "TEMPLATE_VERSION_NOT_FOUND"):
+			response := httpx.NotFound("Template version not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to publish template: %v", err)
+		response := httpx.InternalServerError("Failed to publish template", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Template published successfully", tmpl)
+	return httpx.SendResponse(c, response)
+}
+
+// RollbackTemplate publishes the requested version, or the one before the published version
+func RollbackTemplate(c *fiber.Ctx) error {
+	var input requests.TemplateVersionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			response := httpx.BadRequest("Invalid request body", err)
+			return httpx.SendResponse(c, response)
+		}
+	}
+
+	if validationErrors := input.Validate(); validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	tmpl, err := services.RollbackTemplate(c.Params("name"), templateLocale(c), input.Version)
+	if err != nil {
+		switch {
+		case errors.IsCode(err, "TEMPLATE_NOT_FOUND"):
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "TEMPLATE_VERSION_NOT_FOUND"):
+			response := httpx.NotFound("Template version not found")
+			return httpx.SendResponse(c, response)
+		case errors.IsCode(err, "NO_PREVIOUS_TEMPLATE_VERSION"):
+			response := httpx.Conflict("Template has no version before the published one", err)
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to roll back template: %v", err)
+		response := httpx.InternalServerError("Failed to roll back template", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Template rolled back successfully", tmpl)
+	return httpx.SendResponse(c, response)
+}
+
+func DeleteTemplate(c *fiber.Ctx) error {
+	if err := services.DeleteTemplate(c.Params("name"), templateLocale(c)); err != nil {
+		if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to delete template: %v", err)
+		response := httpx.InternalServerError("Failed to delete template", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Template deleted successfully", nil)
+	return httpx.SendResponse(c, response)
+}
+
+// RenderTemplate previews the template with the given data without creating a mail or sending anything
+func RenderTemplate(c *fiber.Ctx) error {
+	var input requests.RenderTemplateRequest
+	if err := c.BodyParser(&input); err != nil {
+		response := httpx.BadRequest("Invalid request body", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	validationErrors := append(input.Validate(), services.ValidateTenant(input.Tenant)...)
+	if validationErrors.HasErrors() {
+		return sendValidationErrors(c, validationErrors)
+	}
+
+	rendered, err := services.RenderTemplate(c.Params("name"), input.Locale, input.Subject, input.Tenant, input.Data)
+	if err != nil {
+		if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+			response := httpx.NotFound("Template not found")
+			return httpx.SendResponse(c, response)
+		}
+		if details, ok := templateErrorDetails(err); ok {
+			response := httpx.UnprocessableEntity("Failed to render template", err)
+			response.Data = details
+			return httpx.SendResponse(c, response)
+		}
+		log.Printf("failed to render template: %v", err)
+		response := httpx.InternalServerError("Failed to render template", err)
+		return httpx.SendResponse(c, response)
+	}
+
+	response := httpx.OK("Template rendered successfully", rendered)
+	return httpx.SendResponse(c, response)
+}
+
+// templateErrorDetails returns the code, failing part, line and column of a template parse or execution error
+func templateErrorDetails(err error) (map[string]interface{}, bool) {
+	templateErr, ok := err.(*errors.Error)
+	if !ok {
+		return nil, false
+	}
+
+	switch templateErr.Code {
+	case "PARSE_TEMPLATE", "EXECUTE_TEMPLATE", "PARSE_SUBJECT", "EXECUTE_SUBJECT", "MISSING_TEMPLATE_KEY", "INLINE_CSS",
+		"INVALID_TEMPLATE_METADATA", "INVALID_TEMPLATE_SCHEMA", "LAYOUT_NOT_FOUND":
+	default:
+		return nil, false
+	}
+
+	details := map[string]interface{}{"code": templateErr.Code}
+	for _, key := range []string{"part", "line", "column", "key", "layout", "error"} {
+		if value, ok := templateErr.Metadata[key]; ok {
+			details[key] = value
+		}
+	}
+	return details, true
+}
+
+// templateLocale returns the ?locale= query parameter that selects a template translation,
+// empty for the default template
+func templateLocale(c *fiber.Ctx) string {
+	return requests.CanonicalLocale(c.Query("locale"))
+}
+
+// GetTemplateCacheStats reports the hit and miss counts of the compiled template cache
+func GetTemplateCacheStats(c *fiber.Ctx) error {
+	response := httpx.OK("Template cache stats fetched successfully", services.GetTemplateCacheStats())
+	return httpx.SendResponse(c, response)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// sendValidationErrors converts validator.ValidationErrors to []httpx.ValidationError and sends a 422 response
+func sendValidationErrors(c *fiber.Ctx, validationErrors validator.ValidationErrors) error {
+	httpxErrors := make([]httpx.ValidationError, len(validationErrors))
+	for i, err := range validationErrors {
+		httpxErrors[i] = httpx.ValidationError{
+			Field:   err.Field,
+			Message: err.Message,
+		}
+	}
+	response := httpx.UnprocessableEntityWithValidation("Validation failed", httpxErrors)
+	return httpx.SendValidationResponse(c, response)
+}
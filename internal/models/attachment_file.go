@@ -0,0 +1,15 @@
+package models
+
+import (
+	"github.com/kerimovok/go-pkg-database/sql"
+)
+
+// AttachmentFile is a file uploaded through the attachments API that mails can reference by ID
+type AttachmentFile struct {
+	sql.BaseModel
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	StorageKey  string `json:"-"`
+}
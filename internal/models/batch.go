@@ -0,0 +1,13 @@
+package models
+
+import (
+	"github.com/kerimovok/go-pkg-database/sql"
+)
+
+// MailBatch groups the mails created by a single batch send
+type MailBatch struct {
+	sql.BaseModel
+	Subject  string `json:"subject"`
+	Template string `json:"template"`
+	Total    int    `json:"total"`
+}
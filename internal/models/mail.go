@@ -1,23 +1,86 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/kerimovok/go-pkg-database/sql"
 )
 
+// Mail statuses
+const (
+	MailStatusPending       = "pending"
+	MailStatusScheduled     = "scheduled"
+	MailStatusQueued        = "queued"
+	MailStatusSending       = "sending"
+	MailStatusSent          = "sent"
+	MailStatusPartiallySent = "partially_sent"
+	MailStatusFailed        = "failed"
+	MailStatusCancelled     = "cancelled"
+)
+
+// MailStatuses lists every mail status
+var MailStatuses = []string{
+	MailStatusPending,
+	MailStatusScheduled,
+	MailStatusQueued,
+	MailStatusSending,
+	MailStatusSent,
+	MailStatusPartiallySent,
+	MailStatusFailed,
+	MailStatusCancelled,
+}
+
 type Attachment struct {
 	sql.BaseModel
-	MailID uuid.UUID `json:"mailId"`
-	File   string    `json:"file"`
+	MailID uuid.UUID `json:"mailId" gorm:"index"`
+	// File is the storage path of a file referenced by path, empty for uploads
+	File string `json:"file,omitempty"`
+
+	// AttachmentFileID is set when the attachment references an uploaded file instead of a path.
+	// It is cleared when the upload is deleted, leaving neither set.
+	AttachmentFileID *uuid.UUID      `json:"attachmentFileId,omitempty" gorm:"type:uuid;index"`
+	AttachmentFile   *AttachmentFile `json:"attachmentFile,omitempty" gorm:"constraint:OnDelete:SET NULL"`
 }
 
 type Mail struct {
 	sql.BaseModel
-	To          string       `json:"to"`
-	Subject     string       `json:"subject"`
-	Template    string       `json:"template"`
-	Data        sql.JSONB    `json:"data" gorm:"type:jsonb"`
-	Status      string       `json:"status"`
-	Error       string       `json:"error,omitempty"`
-	Attachments []Attachment `json:"attachments"`
+	To          StringList      `json:"to" gorm:"column:to_addresses;type:jsonb"`
+	Cc          StringList      `json:"cc,omitempty" gorm:"column:cc_addresses;type:jsonb"`
+	Bcc         StringList      `json:"bcc,omitempty" gorm:"column:bcc_addresses;type:jsonb"`
+	ReplyTo     StringList      `json:"replyTo,omitempty" gorm:"column:reply_to_addresses;type:jsonb"`
+	Subject     string          `json:"subject"`
+	Template    string          `json:"template"`
+	Data        sql.JSONB       `json:"data" gorm:"type:jsonb"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attachments []Attachment    `json:"attachments"`
+	Recipients  []MailRecipient `json:"recipients,omitempty"`
+	BatchID     *uuid.UUID      `json:"batchId,omitempty" gorm:"type:uuid;index"`
+	SendAt      *time.Time      `json:"sendAt,omitempty" gorm:"index"`
+
+	// Locale selects the template translation, empty for the default template
+	Locale string `json:"locale,omitempty"`
+
+	// From overrides the sender identity of the template and SMTP_FROM
+	From string `json:"from,omitempty" gorm:"column:from_address"`
+	// Category is taken from the request, or from the template once the mail is sent
+	Category string `json:"category,omitempty" gorm:"index"`
+	// Tenant selects the tenant's brand variables
+	Tenant string `json:"tenant,omitempty" gorm:"index"`
+
+	// ErrorCode identifies why the mail failed, e.g. MISSING_TEMPLATE_KEY for permanent rendering errors
+	ErrorCode string `json:"errorCode,omitempty"`
+
+	// TemplateVersion is the database template version the mail was rendered with, nil for file templates.
+	// TemplateVersionID identifies that version's row, which is kept even if the template is deleted or recreated.
+	TemplateVersion   *int       `json:"templateVersion,omitempty"`
+	TemplateVersionID *uuid.UUID `json:"templateVersionId,omitempty" gorm:"type:uuid;index"`
+
+	// ResentFromID links a manual resend to the mail it was copied from
+	ResentFromID *uuid.UUID `json:"resentFromId,omitempty" gorm:"type:uuid;index"`
+
+	// IdempotencyKey deduplicates retried submissions; RequestHash detects key reuse with a different payload
+	IdempotencyKey *string `json:"idempotencyKey,omitempty" gorm:"uniqueIndex"`
+	RequestHash    string  `json:"-"`
 }
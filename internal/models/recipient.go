@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-database/sql"
+)
+
+// Recipient kinds
+const (
+	RecipientKindTo  = "to"
+	RecipientKindCc  = "cc"
+	RecipientKindBcc = "bcc"
+)
+
+// Recipient statuses
+const (
+	RecipientStatusPending = "pending"
+	RecipientStatusSent    = "sent"
+	RecipientStatusFailed  = "failed"
+)
+
+// MailRecipient tracks delivery to a single address of a mail
+type MailRecipient struct {
+	sql.BaseModel
+	MailID       uuid.UUID  `json:"mailId" gorm:"type:uuid;index"`
+	Address      string     `json:"address" gorm:"index"`
+	Kind         string     `json:"kind"`
+	Status       string     `json:"status" gorm:"index"`
+	SMTPResponse string     `json:"smtpResponse,omitempty"`
+	SentAt       *time.Time `json:"sentAt,omitempty"`
+	FailedAt     *time.Time `json:"failedAt,omitempty"`
+}
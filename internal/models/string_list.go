@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StringList is a list of strings, such as email addresses, stored as a JSONB array
+type StringList []string
+
+// Value implements the driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal string list: %w", err)
+	}
+	return data, nil
+}
+
+// Scan implements the sql.Scanner interface
+func (l *StringList) Scan(value any) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into string list", value)
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to unmarshal string list: %w", err)
+	}
+	*l = list
+	return nil
+}
+
+// String joins the list for logging
+func (l StringList) String() string {
+	return strings.Join(l, ", ")
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-database/sql"
+)
+
+// Template is an email template managed through the API. Templates stored in the database
+// take precedence over files of the same name and locale in the templates directory.
+// An empty locale marks the default translation used when no locale matches.
+// Subject and bodies hold the content of the published version, which is what mails are rendered with.
+// Subject, From and Category are defaults that the fields of a mail request override.
+type Template struct {
+	sql.BaseModel
+	Name             string `json:"name" gorm:"uniqueIndex:idx_templates_name_locale;not null"`
+	Locale           string `json:"locale" gorm:"uniqueIndex:idx_templates_name_locale;not null;default:''"`
+	Subject          string `json:"subject"`
+	HTMLBody         string `json:"htmlBody" gorm:"type:text"`
+	TextBody         string `json:"textBody,omitempty" gorm:"type:text"`
+	PublishedVersion int    `json:"publishedVersion"`
+	LatestVersion    int    `json:"latestVersion"`
+	// PublishedVersionID is the ID of the published version row, which mails record as the content they were rendered with
+	PublishedVersionID *uuid.UUID `json:"publishedVersionId,omitempty" gorm:"type:uuid"`
+
+	// Schema is an optional JSON Schema that the data of mails using this template must satisfy
+	Schema sql.JSONB `json:"schema,omitempty" gorm:"type:jsonb"`
+	// StrictMode overrides TEMPLATE_STRICT_MODE for this template when set
+	StrictMode *bool `json:"strictMode,omitempty"`
+	// InlineCSS overrides TEMPLATE_INLINE_CSS for this template when set
+	InlineCSS *bool `json:"inlineCss,omitempty" gorm:"column:inline_css"`
+
+	// From is the sender identity, e.g. "Billing <billing@example.com>", used instead of SMTP_FROM
+	From string `json:"from,omitempty" gorm:"column:from_address"`
+	// Category groups mails by purpose, e.g. "transactional" or "marketing"
+	Category string `json:"category,omitempty"`
+	// RequiredVars lists the data keys every mail must provide, nested keys separated by dots
+	RequiredVars StringList `json:"requiredVars,omitempty" gorm:"type:jsonb"`
+}
+
+// TemplateVersion is an immutable snapshot of a template's content. Every edit creates a new version.
+// Versions outlive their template so mails keep pointing at the content they were rendered with.
+type TemplateVersion struct {
+	sql.BaseModel
+	TemplateID uuid.UUID `json:"templateId" gorm:"type:uuid;not null;uniqueIndex:idx_template_versions_template_version"`
+	Version    int       `json:"version" gorm:"not null;uniqueIndex:idx_template_versions_template_version"`
+	Subject    string    `json:"subject"`
+	HTMLBody   string    `json:"htmlBody" gorm:"type:text"`
+	TextBody   string    `json:"textBody,omitempty" gorm:"type:text"`
+	Schema     sql.JSONB `json:"schema,omitempty" gorm:"type:jsonb"`
+	StrictMode *bool     `json:"strictMode,omitempty"`
+	InlineCSS  *bool     `json:"inlineCss,omitempty" gorm:"column:inline_css"`
+
+	From         string     `json:"from,omitempty" gorm:"column:from_address"`
+	Category     string     `json:"category,omitempty"`
+	RequiredVars StringList `json:"requiredVars,omitempty" gorm:"type:jsonb"`
+}
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mailer-api/internal/requests"
 	"mailer-api/internal/services"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/errors"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -21,11 +24,39 @@ type Consumer struct {
 }
 
 type EmailTask struct {
-	To       string                 `json:"to"`
-	Subject  string                 `json:"subject"`
-	Template string                 `json:"template"`
-	Data     map[string]interface{} `json:"data"`
-	Type     string                 `json:"type"`
+	To          requests.AddressList         `json:"to"`
+	Cc          requests.AddressList         `json:"cc,omitempty"`
+	Bcc         requests.AddressList         `json:"bcc,omitempty"`
+	ReplyTo     requests.AddressList         `json:"replyTo,omitempty"`
+	Subject     string                       `json:"subject"`
+	Template    string                       `json:"template"`
+	Data        map[string]interface{}       `json:"data"`
+	Type        string                       `json:"type"`
+	SendAt      *time.Time                   `json:"sendAt,omitempty"`
+	Attachments []requests.AttachmentRequest `json:"attachments,omitempty"`
+	Locale      string                       `json:"locale,omitempty"`
+	From        string                       `json:"from,omitempty"`
+	Category    string                       `json:"category,omitempty"`
+	Tenant      string                       `json:"tenant,omitempty"`
+}
+
+// MailRequest maps the task onto the request shared with the REST API
+func (t EmailTask) MailRequest() requests.MailRequest {
+	return requests.MailRequest{
+		To:          t.To,
+		Cc:          t.Cc,
+		Bcc:         t.Bcc,
+		ReplyTo:     t.ReplyTo,
+		Subject:     t.Subject,
+		Template:    t.Template,
+		Data:        t.Data,
+		SendAt:      t.SendAt,
+		Attachments: t.Attachments,
+		Locale:      t.Locale,
+		From:        t.From,
+		Category:    t.Category,
+		Tenant:      t.Tenant,
+	}
 }
 
 func NewConsumer() (*Consumer, error) {
@@ -149,29 +180,64 @@ func (c *Consumer) processEmailTask(msg amqp.Delivery) {
 		return
 	}
 
-	log.Printf("Processing email task for user: %s, type: %s (attempt %d/%d)", emailTask.To, emailTask.Type, retryCount+1, maxRetries)
+	mailRequest := emailTask.MailRequest()
+	validationErrors := mailRequest.Validate()
+	validationErrors = append(validationErrors, services.ValidateTenant(mailRequest.Tenant)...)
+	if validationErrors.HasErrors() {
+		// Invalid requests or tenants will never succeed, send to DLQ
+		log.Printf("Invalid email task: %v", validationErrors)
+		if err := msg.Reject(false); err != nil {
+			log.Printf("Failed to reject invalid message: %v", err)
+		}
+		return
+	}
 
-	// Use unified email processing (note: queue-based emails typically don't have attachments)
-	mail, err := services.ProcessEmailRequest(emailTask.To, emailTask.Subject, emailTask.Template, emailTask.Data, nil)
+	// An unknown template, a missing subject or data that does not match the template's schema
+	// will never succeed, send to DLQ
+	check, dataErrors, err := services.ResolveTemplateCheck(mailRequest.Template, mailRequest.Locale)
 	if err != nil {
-		log.Printf("Failed to process email task (attempt %d/%d): %v", retryCount+1, maxRetries, err)
-
-		// Increment retry count and requeue with delay
-		newHeaders := amqp.Table{}
-		if msg.Headers == nil {
-			msg.Headers = amqp.Table{}
+		log.Printf("Failed to load template: %v", err)
+		c.retryEmailTask(msg, retryCount, err)
+		return
+	}
+	if check != nil {
+		dataErrors = append(dataErrors, check.ValidateSubject(mailRequest.Subject)...)
+		schemaErrors, err := check.ValidateData(mailRequest.Data)
+		if err != nil {
+			log.Printf("Failed to validate template data: %v", err)
+			c.retryEmailTask(msg, retryCount, err)
+			return
 		}
-		newHeaders["x-retry-count"] = retryCount + 1
-		newHeaders["x-last-error"] = err.Error()
-		newHeaders["x-last-retry"] = time.Now().Unix()
-
-		// Reject and requeue with delay
+		dataErrors = append(dataErrors, schemaErrors...)
+	}
+	if dataErrors.HasErrors() {
+		log.Printf("Email task does not match the template: %v", dataErrors)
 		if err := msg.Reject(false); err != nil {
-			log.Printf("Failed to reject message for retry: %v", err)
+			log.Printf("Failed to reject invalid message: %v", err)
 		}
+		return
+	}
 
-		// Schedule retry with exponential backoff
-		c.scheduleRetry(msg.Body, newHeaders, calculateRetryDelay(retryCount))
+	log.Printf("Processing email task for user: %s, type: %s (attempt %d/%d)", strings.Join(emailTask.To, ", "), emailTask.Type, retryCount+1, maxRetries)
+
+	// Use unified email processing
+	// The AMQP MessageId doubles as idempotency key so redelivered messages are not sent twice,
+	// namespaced so it cannot collide with an HTTP Idempotency-Key
+	if msg.MessageId != "" {
+		mailRequest.IdempotencyKey = "amqp:" + msg.MessageId
+	}
+	mail, created, err := services.ProcessEmailRequest(mailRequest)
+	if errors.IsCode(err, "IDEMPOTENCY_KEY_REUSED") {
+		// Same MessageId with a different payload will never succeed, send to DLQ
+		log.Printf("Rejecting email task with reused message ID %s: %v", msg.MessageId, err)
+		if err := msg.Reject(false); err != nil {
+			log.Printf("Failed to reject message with reused message ID: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to process email task (attempt %d/%d): %v", retryCount+1, maxRetries, err)
+		c.retryEmailTask(msg, retryCount, err)
 		return
 	}
 
@@ -179,9 +245,33 @@ func (c *Consumer) processEmailTask(msg amqp.Delivery) {
 	if err := msg.Ack(false); err != nil {
 		log.Printf("Failed to acknowledge message: %v", err)
 	}
+	if !created {
+		log.Printf("Duplicate email task %s, already processed as mail %s", msg.MessageId, mail.ID.String())
+		return
+	}
 	log.Printf("Email processed successfully from queue: %s", mail.ID.String())
 }
 
+// retryEmailTask rejects the message and publishes it again after an exponential backoff delay
+func (c *Consumer) retryEmailTask(msg amqp.Delivery, retryCount int, cause error) {
+	// Increment retry count and requeue with delay
+	newHeaders := amqp.Table{}
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	newHeaders["x-retry-count"] = retryCount + 1
+	newHeaders["x-last-error"] = cause.Error()
+	newHeaders["x-last-retry"] = time.Now().Unix()
+
+	// Reject and requeue with delay
+	if err := msg.Reject(false); err != nil {
+		log.Printf("Failed to reject message for retry: %v", err)
+	}
+
+	// Schedule retry with exponential backoff
+	c.scheduleRetry(msg.Body, msg.MessageId, newHeaders, calculateRetryDelay(retryCount))
+}
+
 func (c *Consumer) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -352,8 +442,8 @@ func calculateRetryDelay(retryCount int) time.Duration {
 	return delay
 }
 
-// scheduleRetry schedules a message for retry with delay
-func (c *Consumer) scheduleRetry(body []byte, headers amqp.Table, delay time.Duration) {
+// scheduleRetry schedules a message for retry with delay, keeping its MessageId for deduplication
+func (c *Consumer) scheduleRetry(body []byte, messageID string, headers amqp.Table, delay time.Duration) {
 	// In a production system, you might want to use a proper delay queue
 	// For now, we'll use a simple goroutine with sleep
 	go func() {
@@ -367,6 +457,7 @@ func (c *Consumer) scheduleRetry(body []byte, headers amqp.Table, delay time.Dur
 			false,                 // immediate
 			amqp.Publishing{
 				ContentType:  "application/json",
+				MessageId:    messageID,
 				Body:         body,
 				Headers:      headers,
 				DeliveryMode: amqp.Persistent,
@@ -0,0 +1,66 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// AddressList accepts either a single address string or an array of addresses in JSON
+type AddressList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *AddressList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*a = nil
+		} else {
+			*a = AddressList{single}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("address list must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// validateAddressList checks every address in the list, reporting errors as field[index]
+func validateAddressList(field string, list AddressList, required bool) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+
+	if required && len(list) == 0 {
+		errs = append(errs, validator.FieldError{Field: field, Message: "field is required", Tag: "required"})
+	}
+
+	for i, address := range list {
+		if !config.IsValidEmail(address) {
+			errs = append(errs, validator.FieldError{
+				Field:   fmt.Sprintf("%s[%d]", field, i),
+				Message: "invalid email format",
+				Value:   address,
+				Tag:     "email",
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateSender checks an optional sender identity such as "Billing <billing@example.com>"
+func validateSender(field, sender string) validator.ValidationErrors {
+	if sender == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(sender); err != nil {
+		return validator.ValidationErrors{{Field: field, Message: "must be an email address, optionally with a name as in 'Name <address>'", Value: sender}}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package requests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MailCursor is the keyset position of the last mail returned in a page
+type MailCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// EncodeMailCursor returns the opaque token clients pass back as ?cursor=
+func EncodeMailCursor(cursor MailCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeMailCursor parses a token produced by EncodeMailCursor
+func DecodeMailCursor(token string) (MailCursor, error) {
+	var cursor MailCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(raw, &cursor)
+	return cursor, err
+}
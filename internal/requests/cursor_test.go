@@ -0,0 +1,40 @@
+package requests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMailCursorRoundTrip(t *testing.T) {
+	cursors := []MailCursor{
+		{CreatedAt: time.Date(2026, time.March, 1, 12, 30, 45, 123456789, time.UTC), ID: uuid.New()},
+		{CreatedAt: time.Date(2026, time.March, 1, 13, 30, 45, 0, time.FixedZone("CET", 3600)), ID: uuid.New()},
+		{},
+	}
+	for _, cursor := range cursors {
+		token := EncodeMailCursor(cursor)
+		decoded, err := DecodeMailCursor(token)
+		if err != nil {
+			t.Fatalf("DecodeMailCursor(%q): %v", token, err)
+		}
+		if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+			t.Errorf("round trip of %+v = %+v", cursor, decoded)
+		}
+	}
+}
+
+func TestDecodeMailCursorRejectsInvalidTokens(t *testing.T) {
+	tokens := []string{
+		"not base64!",
+		EncodeMailCursor(MailCursor{})[:5],
+		"bm90IGpzb24",                         // "not json"
+		"eyJ0IjoieWVzdGVyZGF5IiwiaWQiOiJ4In0", // {"t":"yesterday","id":"x"}
+	}
+	for _, token := range tokens {
+		if _, err := DecodeMailCursor(token); err == nil {
+			t.Errorf("DecodeMailCursor(%q) succeeded, want an error", token)
+		}
+	}
+}
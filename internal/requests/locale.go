@@ -0,0 +1,30 @@
+package requests
+
+import (
+	"golang.org/x/text/language"
+
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// validateLocale checks an optional BCP 47 locale and rewrites it in canonical form, e.g. "de-at" becomes "de-AT"
+func validateLocale(field string, locale *string) validator.ValidationErrors {
+	if *locale == "" {
+		return nil
+	}
+
+	tag, err := language.Parse(*locale)
+	if err != nil {
+		return validator.ValidationErrors{{Field: field, Message: "locale must be a BCP 47 language tag such as 'de' or 'de-AT'", Value: *locale}}
+	}
+
+	*locale = tag.String()
+	return nil
+}
+
+// CanonicalLocale returns the canonical form of a locale, or the input unchanged if it is not a BCP 47 language tag
+func CanonicalLocale(locale string) string {
+	if tag, err := language.Parse(locale); err == nil && locale != "" {
+		return tag.String()
+	}
+	return locale
+}
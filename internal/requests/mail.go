@@ -1,13 +1,303 @@
 package requests
 
+import (
+	"encoding/base64"
+	"fmt"
+	"mailer-api/internal/models"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// BrandDataKey is the data key the brand variables are merged under, e.g. {{.Brand.companyName}}.
+// Requests may not set it themselves.
+const BrandDataKey = "Brand"
+
 type MailRequest struct {
-	To          string                 `json:"to" validate:"required,email"`
-	Subject     string                 `json:"subject" validate:"required"`
+	To          AddressList            `json:"to"`
+	Cc          AddressList            `json:"cc,omitempty"`
+	Bcc         AddressList            `json:"bcc,omitempty"`
+	ReplyTo     AddressList            `json:"replyTo,omitempty"`
+	Subject     string                 `json:"subject,omitempty"`
 	Template    string                 `json:"template" validate:"required"`
 	Data        map[string]interface{} `json:"data" validate:"required"`
 	Attachments []AttachmentRequest    `json:"attachments,omitempty"`
+	SendAt      *time.Time             `json:"sendAt,omitempty"`
+	Locale      string                 `json:"locale,omitempty"`
+
+	// From and Category override the defaults of the template; Subject does too when set
+	From     string `json:"from,omitempty"`
+	Category string `json:"category,omitempty"`
+	// Tenant selects the brand variables of a tenant from the brand configuration
+	Tenant string `json:"tenant,omitempty"`
+
+	// IdempotencyKey comes from the Idempotency-Key header or the AMQP MessageId, never from the body
+	IdempotencyKey string `json:"-"`
+}
+
+// Validate validates the struct tags, every recipient address list, the sender, the data, the attachments and the locale
+func (r *MailRequest) Validate() validator.ValidationErrors {
+	errs := validator.ValidateStruct(r)
+	errs = append(errs, r.validateRecipients()...)
+	errs = append(errs, validateSender("from", r.From)...)
+	errs = append(errs, validateReservedData("data", r.Data)...)
+	errs = append(errs, validateAttachments(r.Attachments)...)
+	return append(errs, validateLocale("locale", &r.Locale)...)
 }
 
+// validateReservedData reports a data map that sets BrandDataKey
+func validateReservedData(field string, data map[string]interface{}) validator.ValidationErrors {
+	if _, ok := data[BrandDataKey]; ok {
+		return validator.ValidationErrors{{Field: field + "." + BrandDataKey, Message: BrandDataKey + " is reserved for the brand variables"}}
+	}
+	return nil
+}
+
+// validateRecipients validates the to, cc, bcc and replyTo address lists
+func (r *MailRequest) validateRecipients() validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	errs = append(errs, validateAddressList("to", r.To, true)...)
+	errs = append(errs, validateAddressList("cc", r.Cc, false)...)
+	errs = append(errs, validateAddressList("bcc", r.Bcc, false)...)
+	errs = append(errs, validateAddressList("replyTo", r.ReplyTo, false)...)
+	return errs
+}
+
+// AttachmentRequest references a file in the attachments directory, an uploaded attachment by ID,
+// or carries the file inline as base64 content with a filename and content type
 type AttachmentRequest struct {
-	File string `json:"file" validate:"required"`
+	File        string `json:"file,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+
+	// decoded holds Content once validation has decoded it
+	decoded []byte
+}
+
+// DecodedContent returns the inline content decoded from base64, reusing the result of validation
+func (a *AttachmentRequest) DecodedContent() ([]byte, error) {
+	if a.decoded != nil {
+		return a.decoded, nil
+	}
+	return base64.StdEncoding.DecodeString(a.Content)
+}
+
+// MaxAttachmentSize returns the per-file size limit in bytes
+func MaxAttachmentSize() int64 {
+	return int64(config.GetEnvInt("ATTACHMENT_MAX_FILE_SIZE", 10*1024*1024))
+}
+
+// MaxAttachmentsTotalSize returns the limit in bytes for all inline attachments of one mail
+func MaxAttachmentsTotalSize() int64 {
+	return int64(config.GetEnvInt("ATTACHMENT_MAX_TOTAL_SIZE", 25*1024*1024))
+}
+
+// validateAttachments checks that every attachment references exactly one source
+// and that inline content is valid base64 within the size limits
+func validateAttachments(attachments []AttachmentRequest) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	var totalSize int64
+	maxSize, maxTotalSize := MaxAttachmentSize(), MaxAttachmentsTotalSize()
+
+	for i := range attachments {
+		attachment := &attachments[i]
+		field := fmt.Sprintf("attachments[%d]", i)
+
+		sources := 0
+		for _, source := range []string{attachment.File, attachment.ID, attachment.Content} {
+			if source != "" {
+				sources++
+			}
+		}
+
+		switch {
+		case sources == 0:
+			errs = append(errs, validator.FieldError{Field: field, Message: "one of file, id or content is required", Tag: "required"})
+		case sources > 1:
+			errs = append(errs, validator.FieldError{Field: field, Message: "only one of file, id or content may be set"})
+		case attachment.File != "" && !filepath.IsLocal(filepath.FromSlash(attachment.File)):
+			errs = append(errs, validator.FieldError{Field: field + ".file", Message: "file must be a relative path inside the attachment storage", Value: attachment.File})
+		case attachment.ID != "" && !config.IsValidUUID(attachment.ID):
+			errs = append(errs, validator.FieldError{Field: field + ".id", Message: "invalid UUID format", Value: attachment.ID, Tag: "uuid"})
+		case attachment.Content != "":
+			if attachment.Filename == "" {
+				errs = append(errs, validator.FieldError{Field: field + ".filename", Message: "filename is required with inline content", Tag: "required"})
+			}
+			content, err := attachment.DecodedContent()
+			if err != nil {
+				errs = append(errs, validator.FieldError{Field: field + ".content", Message: "content must be base64 encoded"})
+				continue
+			}
+			attachment.decoded = content
+			size := int64(len(content))
+			if size > maxSize {
+				errs = append(errs, validator.FieldError{Field: field + ".content", Message: fmt.Sprintf("attachment is %d bytes, exceeding the maximum of %d bytes", size, maxSize)})
+			}
+			totalSize += size
+		}
+	}
+
+	if totalSize > maxTotalSize {
+		errs = append(errs, validator.FieldError{Field: "attachments", Message: fmt.Sprintf("attachments total %d bytes, exceeding the maximum of %d bytes", totalSize, maxTotalSize)})
+	}
+
+	return errs
+}
+
+// MailListQuery holds the filters, sort order and cursor accepted by GET /api/v1/mails
+type MailListQuery struct {
+	Status        string `query:"status"`
+	To            string `query:"to"`
+	Template      string `query:"template"`
+	CreatedAfter  string `query:"createdAfter"`
+	CreatedBefore string `query:"createdBefore"`
+	Sort          string `query:"sort"`
+	Limit         int    `query:"limit"`
+	Cursor        string `query:"cursor"`
+}
+
+const (
+	DefaultMailListLimit = 50
+	MaxMailListLimit     = 200
+)
+
+// Validate checks the optional query parameters and applies defaults
+func (q *MailListQuery) Validate() validator.ValidationErrors {
+	var errs validator.ValidationErrors
+
+	if q.Status != "" && !slices.Contains(models.MailStatuses, q.Status) {
+		errs = append(errs, validator.FieldError{Field: "status", Message: "status must be one of " + strings.Join(models.MailStatuses, ", "), Value: q.Status})
+	}
+
+	if q.Sort == "" {
+		q.Sort = "desc"
+	}
+	if q.Sort != "asc" && q.Sort != "desc" {
+		errs = append(errs, validator.FieldError{Field: "sort", Message: "sort must be 'asc' or 'desc'", Value: q.Sort})
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultMailListLimit
+	}
+	if q.Limit < 1 || q.Limit > MaxMailListLimit {
+		errs = append(errs, validator.FieldError{Field: "limit", Message: "limit must be between 1 and 200"})
+	}
+
+	if q.CreatedAfter != "" {
+		if _, err := time.Parse(time.RFC3339, q.CreatedAfter); err != nil {
+			errs = append(errs, validator.FieldError{Field: "createdAfter", Message: "createdAfter must be an RFC 3339 timestamp", Value: q.CreatedAfter})
+		}
+	}
+	if q.CreatedBefore != "" {
+		if _, err := time.Parse(time.RFC3339, q.CreatedBefore); err != nil {
+			errs = append(errs, validator.FieldError{Field: "createdBefore", Message: "createdBefore must be an RFC 3339 timestamp", Value: q.CreatedBefore})
+		}
+	}
+
+	if q.Cursor != "" {
+		if _, err := DecodeMailCursor(q.Cursor); err != nil {
+			errs = append(errs, validator.FieldError{Field: "cursor", Message: "cursor is invalid"})
+		}
+	}
+
+	return errs
+}
+
+// BatchMailRequest sends one template to many recipients, each with its own data
+type BatchMailRequest struct {
+	Cc          AddressList         `json:"cc,omitempty"`
+	Bcc         AddressList         `json:"bcc,omitempty"`
+	ReplyTo     AddressList         `json:"replyTo,omitempty"`
+	Subject     string              `json:"subject,omitempty"`
+	Template    string              `json:"template" validate:"required"`
+	Recipients  []BatchRecipient    `json:"recipients" validate:"required,min=1"`
+	Attachments []AttachmentRequest `json:"attachments,omitempty"`
+	SendAt      *time.Time          `json:"sendAt,omitempty"`
+	Locale      string              `json:"locale,omitempty"`
+	From        string              `json:"from,omitempty"`
+	Category    string              `json:"category,omitempty"`
+	Tenant      string              `json:"tenant,omitempty"`
+}
+
+type BatchRecipient struct {
+	To   AddressList            `json:"to"`
+	Data map[string]interface{} `json:"data" validate:"required"`
+}
+
+// Validate validates the batch and every expanded mail request with MailRequest.Validate. Errors of a
+// recipient's own fields are prefixed with its index, errors of the shared fields are reported once.
+func (r *BatchMailRequest) Validate(maxSize int) validator.ValidationErrors {
+	errs := validator.ValidateStruct(r)
+	if len(r.Recipients) > maxSize {
+		return append(errs, validator.FieldError{Field: "recipients", Message: fmt.Sprintf("at most %d recipients are allowed per batch", maxSize)})
+	}
+
+	// Canonicalize the shared locale before it is copied into the expanded requests
+	errs = append(errs, validateLocale("locale", &r.Locale)...)
+
+	for i, mailRequest := range r.MailRequests() {
+		for _, err := range mailRequest.Validate() {
+			if isRecipientField(err.Field) {
+				err.Field = fmt.Sprintf("recipients[%d].%s", i, err.Field)
+			} else if slices.ContainsFunc(errs, func(reported validator.FieldError) bool {
+				return reported.Field == err.Field && reported.Message == err.Message
+			}) {
+				continue
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// isRecipientField reports whether a MailRequest field error belongs to the fields a batch recipient sets
+func isRecipientField(field string) bool {
+	for _, name := range []string{"to", "data"} {
+		if field == name || strings.HasPrefix(field, name+"[") || strings.HasPrefix(field, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// MailRequests expands the batch into one MailRequest per recipient
+func (r *BatchMailRequest) MailRequests() []MailRequest {
+	mailRequests := make([]MailRequest, len(r.Recipients))
+	for i, recipient := range r.Recipients {
+		mailRequests[i] = MailRequest{
+			To:          recipient.To,
+			Cc:          r.Cc,
+			Bcc:         r.Bcc,
+			ReplyTo:     r.ReplyTo,
+			Subject:     r.Subject,
+			Template:    r.Template,
+			Data:        recipient.Data,
+			Attachments: r.Attachments,
+			SendAt:      r.SendAt,
+			Locale:      r.Locale,
+			From:        r.From,
+			Category:    r.Category,
+			Tenant:      r.Tenant,
+		}
+	}
+	return mailRequests
+}
+
+// ResendMailRequest optionally overrides the recipient or data of the mail being resent
+type ResendMailRequest struct {
+	To   AddressList            `json:"to,omitempty"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Validate checks the optional overrides
+func (r *ResendMailRequest) Validate() validator.ValidationErrors {
+	errs := validateAddressList("to", r.To, false)
+	return append(errs, validateReservedData("data", r.Data)...)
 }
@@ -0,0 +1,51 @@
+package requests
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMailListQueryValidateStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		valid  bool
+	}{
+		{status: "", valid: true},
+		{status: "sent", valid: true},
+		{status: "partially_sent", valid: true},
+		{status: "snet"},
+		{status: "SENT"},
+	}
+	for _, test := range tests {
+		query := MailListQuery{Status: test.status}
+		if errs := query.Validate(); errs.HasErrors() == test.valid {
+			t.Errorf("Validate with status %q = %v, want valid %v", test.status, errs, test.valid)
+		}
+	}
+}
+
+func TestBatchMailRequestValidate(t *testing.T) {
+	input := BatchMailRequest{
+		Cc:       AddressList{"not-an-address"},
+		Template: "welcome",
+		Locale:   "EN-us",
+		Recipients: []BatchRecipient{
+			{To: AddressList{"ada@example.com"}, Data: map[string]interface{}{"name": "Ada"}},
+			{To: AddressList{"invalid"}, Data: map[string]interface{}{BrandDataKey: "x"}},
+			{Data: map[string]interface{}{"name": "Bob"}},
+		},
+	}
+
+	got := fieldsOf(input.Validate(10))
+	want := []string{"cc[0]", "recipients[1].to[0]", "recipients[1].data.Brand", "recipients[2].to"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate fields = %v, want %v", got, want)
+	}
+	if input.Locale != "en-US" {
+		t.Errorf("Validate left locale %q, want it canonicalized to %q", input.Locale, "en-US")
+	}
+
+	if got := fieldsOf(input.Validate(2)); !slices.Equal(got, []string{"recipients"}) {
+		t.Errorf("Validate over the batch size fields = %v, want [recipients]", got)
+	}
+}
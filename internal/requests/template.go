@@ -0,0 +1,100 @@
+package requests
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+// templateNamePattern keeps template names usable as file names and URL segments
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// templateVarPattern matches a data key usable in a template, nested keys separated by dots
+var templateVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+type CreateTemplateRequest struct {
+	Name   string `json:"name" validate:"required"`
+	Locale string `json:"locale,omitempty"`
+	templateContent
+}
+
+// Validate validates the struct tags, the template name, the locale and the template content
+func (r *CreateTemplateRequest) Validate() validator.ValidationErrors {
+	errs := validator.ValidateStruct(r)
+	if r.Name != "" && !templateNamePattern.MatchString(r.Name) {
+		errs = append(errs, validator.FieldError{Field: "name", Message: "name may only contain letters, digits, '-' and '_'", Value: r.Name})
+	}
+	errs = append(errs, validateLocale("locale", &r.Locale)...)
+	return append(errs, r.templateContent.Validate()...)
+}
+
+// UpdateTemplateRequest creates a new, unpublished version of an existing template
+type UpdateTemplateRequest struct {
+	templateContent
+}
+
+// templateContent holds the bodies and mail defaults of a template version
+type templateContent struct {
+	Subject  string                 `json:"subject"`
+	HTMLBody string                 `json:"htmlBody" validate:"required"`
+	TextBody string                 `json:"textBody,omitempty"`
+	Schema   map[string]interface{} `json:"schema,omitempty"`
+
+	// StrictMode fails rendering on missing data keys, defaulting to TEMPLATE_STRICT_MODE when omitted
+	StrictMode *bool `json:"strictMode,omitempty"`
+	// InlineCSS moves <style> rules into style attributes, defaulting to TEMPLATE_INLINE_CSS when omitted
+	InlineCSS *bool `json:"inlineCss,omitempty"`
+
+	// From, Category and RequiredVars are defaults for mails using the template, see models.Template
+	From         string   `json:"from,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	RequiredVars []string `json:"requiredVars,omitempty"`
+}
+
+// Validate validates the struct tags and the mail defaults
+func (c *templateContent) Validate() validator.ValidationErrors {
+	errs := validator.ValidateStruct(c)
+	errs = append(errs, validateSender("from", c.From)...)
+	return append(errs, validateRequiredVars(c.RequiredVars)...)
+}
+
+// validateRequiredVars checks that every required variable is a data key or a dotted path of keys
+func validateRequiredVars(vars []string) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	for i, name := range vars {
+		if !templateVarPattern.MatchString(name) {
+			errs = append(errs, validator.FieldError{Field: fmt.Sprintf("requiredVars[%d]", i), Message: "must be a data key such as 'name' or 'order.id'", Value: name})
+		}
+	}
+	return errs
+}
+
+// TemplateVersionRequest selects the version to publish or roll back to.
+// When omitted, publish uses the latest version and rollback the one before the published version.
+type TemplateVersionRequest struct {
+	Version int `json:"version,omitempty"`
+}
+
+// Validate checks the optional version
+func (r *TemplateVersionRequest) Validate() validator.ValidationErrors {
+	if r.Version < 0 {
+		return validator.ValidationErrors{{Field: "version", Message: "version must be a positive number"}}
+	}
+	return nil
+}
+
+// RenderTemplateRequest previews a template with the given data. Subject overrides the template's default subject
+// and Locale selects the translation the same way it does for mails. Tenant selects the brand variables of a tenant.
+type RenderTemplateRequest struct {
+	Subject string                 `json:"subject,omitempty"`
+	Locale  string                 `json:"locale,omitempty"`
+	Tenant  string                 `json:"tenant,omitempty"`
+	Data    map[string]interface{} `json:"data" validate:"required"`
+}
+
+// Validate validates the struct tags and the locale
+func (r *RenderTemplateRequest) Validate() validator.ValidationErrors {
+	errs := validator.ValidateStruct(r)
+	return append(errs, validateLocale("locale", &r.Locale)...)
+}
@@ -0,0 +1,45 @@
+package requests
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/kerimovok/go-pkg-utils/validator"
+)
+
+func TestTemplateRequestsShareContent(t *testing.T) {
+	body := []byte(`{"name":"welcome","htmlBody":"<p>Hi</p>","from":"not an address","requiredVars":["name","1bad"]}`)
+
+	var create CreateTemplateRequest
+	if err := json.Unmarshal(body, &create); err != nil {
+		t.Fatal(err)
+	}
+	var update UpdateTemplateRequest
+	if err := json.Unmarshal(body, &update); err != nil {
+		t.Fatal(err)
+	}
+	if create.HTMLBody != "<p>Hi</p>" || update.HTMLBody != "<p>Hi</p>" {
+		t.Fatalf("htmlBody not decoded: %q, %q", create.HTMLBody, update.HTMLBody)
+	}
+
+	want := []string{"from", "requiredVars[1]"}
+	for name, errs := range map[string][]string{"create": fieldsOf(create.Validate()), "update": fieldsOf(update.Validate())} {
+		if !slices.Equal(errs, want) {
+			t.Errorf("%s errors = %q, want %q", name, errs, want)
+		}
+	}
+
+	var empty UpdateTemplateRequest
+	if errs := fieldsOf(empty.Validate()); !slices.Equal(errs, []string{"htmlBody"}) {
+		t.Errorf("empty update errors = %q, want htmlBody", errs)
+	}
+}
+
+func fieldsOf(errs validator.ValidationErrors) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
@@ -1,7 +1,9 @@
 package routes
 
 import (
+	"io"
 	"mailer-api/internal/handlers"
+	"mailer-api/internal/requests"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/monitor"
@@ -16,10 +18,66 @@ func SetupRoutes(app *fiber.App) {
 	app.Get("/metrics", monitor.New())
 
 	// Mail routes
-	mail := v1.Group("/mails")
+	mail := v1.Group("/mails", bodyLimit(mailBodyLimit()))
 	mail.Post("/", handlers.SendMail)
 	mail.Get("/", handlers.GetMails)
+	mail.Post("/batch", handlers.SendBatch)
+	mail.Get("/batch/:id", handlers.GetBatchByID)
 	mail.Get("/:id", handlers.GetMailByID)
+	mail.Delete("/:id", handlers.CancelMail)
+	mail.Post("/:id/resend", handlers.ResendMail)
 
-	// TODO: Add routes for attachments
+	// Attachment routes, uploads are streamed and limited by ATTACHMENT_MAX_FILE_SIZE instead
+	attachment := v1.Group("/attachments")
+	attachment.Post("/", handlers.UploadAttachment)
+	attachment.Get("/:id", handlers.GetAttachmentByID)
+	attachment.Get("/:id/content", handlers.DownloadAttachment)
+	attachment.Delete("/:id", handlers.DeleteAttachment)
+
+	// Template routes
+	template := v1.Group("/templates", bodyLimit(jsonBodyLimit))
+	template.Post("/", handlers.CreateTemplate)
+	template.Get("/", handlers.GetTemplates)
+	template.Get("/cache/stats", handlers.GetTemplateCacheStats)
+	template.Get("/:name", handlers.GetTemplateByName)
+	template.Put("/:name", handlers.UpdateTemplate)
+	template.Delete("/:name", handlers.DeleteTemplate)
+	template.Get("/:name/versions", handlers.GetTemplateVersions)
+	template.Get("/:name/versions/:version", handlers.GetTemplateVersion)
+	template.Post("/:name/publish", handlers.PublishTemplate)
+	template.Post("/:name/rollback", handlers.RollbackTemplate)
+	template.Post("/:name/render", handlers.RenderTemplate)
+}
+
+// jsonBodyLimit caps JSON request bodies, which are read fully into memory
+const jsonBodyLimit = 4 * 1024 * 1024
+
+// mailBodyLimit leaves room for the base64 encoded inline attachments allowed per mail
+func mailBodyLimit() int {
+	return jsonBodyLimit + int(requests.MaxAttachmentsTotalSize()*4/3)
+}
+
+// bodyLimit rejects requests whose body exceeds max with 413. Streaming request bodies is enabled
+// for attachment uploads, so fiber's BodyLimit no longer rejects oversized bodies on its own.
+// The declared Content-Length is checked up front, and the body is read through a limited reader
+// so chunked requests without a Content-Length cannot exceed max either.
+func bodyLimit(max int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() > max {
+			return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+		}
+
+		if stream := c.Context().RequestBodyStream(); stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(max)+1))
+			if err != nil {
+				return c.SendStatus(fiber.StatusBadRequest)
+			}
+			if len(body) > max {
+				return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+			}
+			c.Request().SetBody(body)
+		}
+
+		return c.Next()
+	}
 }
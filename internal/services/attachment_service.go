@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stdErrors "errors"
+	"io"
+	"log"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"mailer-api/internal/requests"
+	"mailer-api/internal/storage"
+	"mime"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-database/sql"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// attachmentStorage holds both files referenced by path and uploaded attachments
+var attachmentStorage storage.Storage
+
+// InitAttachmentStorage connects the storage backend selected by ATTACHMENT_STORAGE
+func InitAttachmentStorage() error {
+	backend, err := storage.New()
+	if err != nil {
+		return err
+	}
+	attachmentStorage = backend
+	return nil
+}
+
+// byteCounter counts the bytes written through it
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// StoreAttachment streams an uploaded file to attachment storage while computing its size and checksum
+func StoreAttachment(filename, contentType string, r io.Reader) (*models.AttachmentFile, error) {
+	id := uuid.New()
+	file := models.AttachmentFile{
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		StorageKey:  path.Join("uploads", id.String()),
+	}
+	file.ID = id
+
+	if file.ContentType == "" || file.ContentType == "application/octet-stream" {
+		if detected := mime.TypeByExtension(filepath.Ext(file.Filename)); detected != "" {
+			file.ContentType = detected
+		} else {
+			file.ContentType = "application/octet-stream"
+		}
+	}
+
+	// Read one byte past the limit to detect oversized uploads without buffering them
+	ctx := context.Background()
+	maxSize := requests.MaxAttachmentSize()
+	hash := sha256.New()
+	var size byteCounter
+	content := io.TeeReader(io.LimitReader(r, maxSize+1), io.MultiWriter(hash, &size))
+
+	err := attachmentStorage.Put(ctx, file.StorageKey, content, -1, file.ContentType)
+	if err == nil && int64(size) > maxSize {
+		err = errors.ValidationError("ATTACHMENT_TOO_LARGE", "Attachment exceeds the maximum file size").
+			WithMetadata("maxSize", maxSize)
+	}
+	if err != nil {
+		attachmentStorage.Delete(ctx, file.StorageKey)
+		return nil, err
+	}
+
+	file.Size = int64(size)
+	file.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := database.DB.Create(&file).Error; err != nil {
+		attachmentStorage.Delete(ctx, file.StorageKey)
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// GetAttachment returns the metadata of an uploaded attachment
+func GetAttachment(id string) (*models.AttachmentFile, error) {
+	var file models.AttachmentFile
+	if err := database.DB.Where("id = ?", id).First(&file).Error; err != nil {
+		return nil, errors.NotFoundError("ATTACHMENT_NOT_FOUND", "Attachment not found").WithMetadata("id", id)
+	}
+	return &file, nil
+}
+
+// OpenAttachment opens the content of an uploaded attachment
+func OpenAttachment(file *models.AttachmentFile) (io.ReadCloser, error) {
+	return attachmentStorage.Open(context.Background(), file.StorageKey)
+}
+
+// DeleteAttachment removes an uploaded attachment unless a mail that has not been sent yet still references it.
+// The attachment row is locked while checking its usage, so a mail referencing it cannot be stored in between.
+func DeleteAttachment(id string) error {
+	var file models.AttachmentFile
+	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&file).Error; err != nil {
+			return errors.NotFoundError("ATTACHMENT_NOT_FOUND", "Attachment not found").WithMetadata("id", id)
+		}
+
+		var pending int64
+		if err := tx.Model(&models.Attachment{}).
+			Joins("JOIN mails ON mails.id = attachments.mail_id").
+			Where("attachments.attachment_file_id = ? AND mails.status IN ?", file.ID, []string{
+				models.MailStatusPending, models.MailStatusScheduled, models.MailStatusQueued, models.MailStatusSending,
+			}).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errors.ConflictError("ATTACHMENT_IN_USE", "Attachment is referenced by mails that have not been sent yet").
+				WithMetadata("mails", pending)
+		}
+
+		return tx.Delete(&file).Error
+	})
+	if err != nil {
+		// The transaction wraps errors, errors.IsCode only sees the domain error itself
+		var domainErr *errors.Error
+		if stdErrors.As(err, &domainErr) {
+			return domainErr
+		}
+		return err
+	}
+
+	if err := attachmentStorage.Delete(context.Background(), file.StorageKey); err != nil {
+		log.Printf("Failed to remove attachment content %s: %v", file.ID, err)
+	}
+
+	return nil
+}
+
+// resolveAttachments turns attachment requests into unsaved attachment records,
+// checking that referenced uploads exist and storing inline content as uploads
+// so queued and resent mails can still read it later. The uploads stored for inline
+// content are returned as well, so the caller can discard them when the mail is not stored.
+func resolveAttachments(attachmentRequests []requests.AttachmentRequest) ([]models.Attachment, []*models.AttachmentFile, error) {
+	attachments := make([]models.Attachment, 0, len(attachmentRequests))
+	var stored []*models.AttachmentFile
+	for i := range attachmentRequests {
+		attachmentRequest := &attachmentRequests[i]
+		var file *models.AttachmentFile
+		var err error
+
+		switch {
+		case attachmentRequest.Content != "":
+			content, decodeErr := attachmentRequest.DecodedContent()
+			if decodeErr != nil {
+				discardAttachments(stored)
+				return nil, nil, errors.ValidationError("INVALID_ATTACHMENT_CONTENT", "Attachment content must be base64 encoded").
+					WithMetadata("filename", attachmentRequest.Filename)
+			}
+			file, err = StoreAttachment(attachmentRequest.Filename, attachmentRequest.ContentType, bytes.NewReader(content))
+			if err == nil {
+				stored = append(stored, file)
+			}
+		case attachmentRequest.ID != "":
+			file, err = GetAttachment(attachmentRequest.ID)
+		default:
+			attachments = append(attachments, models.Attachment{File: attachmentRequest.File})
+			continue
+		}
+		if err != nil {
+			discardAttachments(stored)
+			return nil, nil, err
+		}
+		attachments = append(attachments, models.Attachment{
+			AttachmentFileID: &file.ID,
+			AttachmentFile:   file,
+		})
+	}
+	return attachments, stored, nil
+}
+
+// discardAttachments removes uploads stored for a mail that ended up not being stored
+func discardAttachments(files []*models.AttachmentFile) {
+	for _, file := range files {
+		if err := database.DB.Delete(file).Error; err != nil {
+			log.Printf("Failed to remove unused attachment %s: %v", file.ID, err)
+			continue
+		}
+		if err := attachmentStorage.Delete(context.Background(), file.StorageKey); err != nil {
+			log.Printf("Failed to remove attachment content %s: %v", file.ID, err)
+		}
+	}
+}
+
+// attachToMessage adds a mail attachment to the message, streaming its content from attachment storage.
+// Files referenced by path are resolved inside the storage root, so "../" cannot reach other files.
+// An attachment without a path referenced an upload; deleting the upload clears the reference,
+// so such a mail fails instead of sending without its attachment.
+func attachToMessage(m *gomail.Message, attachment models.Attachment) error {
+	if attachment.AttachmentFile != nil {
+		file := attachment.AttachmentFile
+		m.Attach(file.Filename,
+			gomail.SetHeader(map[string][]string{"Content-Type": {file.ContentType}}),
+			gomail.SetCopyFunc(copyFromStorage(file.StorageKey)),
+		)
+		return nil
+	}
+	if attachment.File == "" || attachment.AttachmentFileID != nil {
+		id := ""
+		if attachment.AttachmentFileID != nil {
+			id = attachment.AttachmentFileID.String()
+		}
+		return errors.NotFoundError("ATTACHMENT_NOT_FOUND", "Uploaded attachment no longer exists").WithMetadata("id", id)
+	}
+
+	exists, err := attachmentStorage.Exists(context.Background(), attachment.File)
+	if stdErrors.Is(err, storage.ErrInvalidKey) || (err == nil && !exists) {
+		return errors.NotFoundError("ATTACHMENT_NOT_FOUND", "Attachment file not found").WithMetadata("file", attachment.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	// gomail derives the Content-Type from the file extension
+	m.Attach(path.Base(attachment.File), gomail.SetCopyFunc(copyFromStorage(attachment.File)))
+	return nil
+}
+
+// copyFromStorage returns a gomail copy func that streams the content stored under key
+func copyFromStorage(key string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		content, err := attachmentStorage.Open(context.Background(), key)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		_, err = io.Copy(w, content)
+		return err
+	}
+}
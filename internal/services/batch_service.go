@@ -0,0 +1,109 @@
+package services
+
+import (
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"mailer-api/internal/requests"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-database/sql"
+	"gorm.io/gorm"
+)
+
+// BatchProgress reports the aggregate delivery state of a batch
+type BatchProgress struct {
+	Batch    models.MailBatch `json:"batch"`
+	Statuses map[string]int64 `json:"statuses"`
+	Pending  int64            `json:"pending"`
+	Done     bool             `json:"done"`
+}
+
+// CreateBatch persists a batch and all of its mails in one transaction, then queues them for delivery
+func CreateBatch(input requests.BatchMailRequest) (*models.MailBatch, error) {
+	batch := models.MailBatch{
+		Subject:  input.Subject,
+		Template: input.Template,
+		Total:    len(input.Recipients),
+	}
+
+	attachments, stored, err := resolveAttachments(input.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	mailRequests := input.MailRequests()
+
+	// Only immediately due mails go to the worker pool, scheduled ones are picked up by the scheduler
+	mailIDs := make([]uuid.UUID, 0, len(mailRequests))
+
+	err = sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+
+		for _, mailRequest := range mailRequests {
+			mail := newMailRecord(mailRequest, models.MailStatusQueued)
+			mail.BatchID = &batch.ID
+			if err := insertMail(tx, &mail, attachments); err != nil {
+				return err
+			}
+			if mail.Status == models.MailStatusQueued {
+				mailIDs = append(mailIDs, mail.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		discardAttachments(stored)
+		return nil, err
+	}
+
+	for _, id := range mailIDs {
+		enqueueDelivery(id)
+	}
+
+	return &batch, nil
+}
+
+// GetBatchProgress counts the batch's mails per status
+func GetBatchProgress(id string) (*BatchProgress, error) {
+	var batch models.MailBatch
+	if err := database.DB.Where("id = ?", id).First(&batch).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := database.DB.Model(&models.Mail{}).
+		Select("status, count(*) AS count").
+		Where("batch_id = ?", batch.ID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	progress := &BatchProgress{
+		Batch:    batch,
+		Statuses: make(map[string]int64, len(rows)),
+	}
+	for _, row := range rows {
+		progress.Statuses[row.Status] = row.Count
+		if !isFinalMailStatus(row.Status) {
+			progress.Pending += row.Count
+		}
+	}
+	progress.Done = progress.Pending == 0
+
+	return progress, nil
+}
+
+// isFinalMailStatus reports whether a mail in this status will not change anymore on its own
+func isFinalMailStatus(status string) bool {
+	switch status {
+	case models.MailStatusSent, models.MailStatusPartiallySent, models.MailStatusFailed, models.MailStatusCancelled:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"mailer-api/internal/requests"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// brandConfig holds the brand variables merged under .Brand into the data of every template.
+// Tenant and sender variables override the defaults key by key, sender variables win over tenant ones.
+type brandConfig struct {
+	Default map[string]interface{}            `yaml:"default"`
+	Tenants map[string]map[string]interface{} `yaml:"tenants"`
+	// Senders is keyed by sender address, e.g. billing@example.com
+	Senders map[string]map[string]interface{} `yaml:"senders"`
+}
+
+var brand brandConfig
+
+// InitBrand loads the brand variables from the BRAND_CONFIG_FILE YAML file, e.g.
+//
+//	default:
+//	  companyName: Example
+//	  logoUrl: https://example.com/logo.png
+//	tenants:
+//	  acme:
+//	    companyName: ACME
+//	senders:
+//	  billing@example.com:
+//	    supportEmail: billing@example.com
+//
+// BRAND_<NAME> environment variables override the file defaults, e.g. BRAND_COMPANY_NAME sets .Brand.companyName.
+func InitBrand() error {
+	brand = brandConfig{Default: make(map[string]interface{})}
+
+	if path := config.GetEnv("BRAND_CONFIG_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(content, &brand); err != nil {
+			return fmt.Errorf("invalid brand config %s: %w", path, err)
+		}
+		if brand.Default == nil {
+			brand.Default = make(map[string]interface{})
+		}
+
+		// Sender identities are matched by address regardless of case
+		senders := make(map[string]map[string]interface{}, len(brand.Senders))
+		for address, variables := range brand.Senders {
+			senders[strings.ToLower(address)] = variables
+		}
+		brand.Senders = senders
+	}
+
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, "BRAND_") || name == "BRAND_CONFIG_FILE" {
+			continue
+		}
+		brand.Default[brandVariableName(strings.TrimPrefix(name, "BRAND_"))] = value
+	}
+
+	return nil
+}
+
+// brandVariableName turns the suffix of a BRAND_ environment variable into a camel case key, e.g. LOGO_URL into logoUrl
+func brandVariableName(suffix string) string {
+	words := strings.Split(strings.ToLower(suffix), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// ValidateTenant checks that a tenant, when set, is configured in the brand configuration,
+// so a mistyped tenant is rejected instead of silently sending the default brand
+func ValidateTenant(tenant string) validator.ValidationErrors {
+	if tenant == "" {
+		return nil
+	}
+	if _, ok := brand.Tenants[tenant]; !ok {
+		return validator.ValidationErrors{{Field: "tenant", Message: "tenant is not configured in the brand configuration", Value: tenant}}
+	}
+	return nil
+}
+
+// brandVariables returns the brand variables for a tenant and bare sender address, either of which may be empty
+func brandVariables(tenant, sender string) map[string]interface{} {
+	variables := maps.Clone(brand.Default)
+	if variables == nil {
+		variables = make(map[string]interface{})
+	}
+	if tenant != "" {
+		maps.Copy(variables, brand.Tenants[tenant])
+	}
+	if sender != "" {
+		maps.Copy(variables, brand.Senders[strings.ToLower(sender)])
+	}
+	return variables
+}
+
+// withBrand returns a copy of data with the brand variables for the tenant and sender under requests.BrandDataKey
+func withBrand(data map[string]interface{}, tenant, sender string) map[string]interface{} {
+	branded := make(map[string]interface{}, len(data)+1)
+	maps.Copy(branded, data)
+	branded[requests.BrandDataKey] = brandVariables(tenant, sender)
+	return branded
+}
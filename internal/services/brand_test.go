@@ -0,0 +1,52 @@
+package services
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestBrandVariableName(t *testing.T) {
+	tests := map[string]string{
+		"COMPANY_NAME":  "companyName",
+		"LOGO_URL":      "logoUrl",
+		"COLOR":         "color",
+		"SUPPORT__MAIL": "supportMail",
+		"A_B_C":         "aBC",
+	}
+	for suffix, want := range tests {
+		if got := brandVariableName(suffix); got != want {
+			t.Errorf("brandVariableName(%q) = %q, want %q", suffix, got, want)
+		}
+	}
+}
+
+func TestBrandVariables(t *testing.T) {
+	previous := brand
+	t.Cleanup(func() { brand = previous })
+	brand = brandConfig{
+		Default: map[string]interface{}{"companyName": "Example", "color": "blue", "supportEmail": "help@example.com"},
+		Tenants: map[string]map[string]interface{}{"acme": {"companyName": "ACME", "color": "red"}},
+		Senders: map[string]map[string]interface{}{"billing@example.com": {"supportEmail": "billing@example.com", "color": "green"}},
+	}
+
+	tests := []struct {
+		tenant, sender string
+		want           map[string]interface{}
+	}{
+		{want: map[string]interface{}{"companyName": "Example", "color": "blue", "supportEmail": "help@example.com"}},
+		{tenant: "acme", want: map[string]interface{}{"companyName": "ACME", "color": "red", "supportEmail": "help@example.com"}},
+		// Sender variables win over tenant ones and are matched regardless of case
+		{tenant: "acme", sender: "Billing@Example.com", want: map[string]interface{}{"companyName": "ACME", "color": "green", "supportEmail": "billing@example.com"}},
+		{sender: "other@example.com", want: map[string]interface{}{"companyName": "Example", "color": "blue", "supportEmail": "help@example.com"}},
+	}
+	for _, test := range tests {
+		if got := brandVariables(test.tenant, test.sender); !maps.Equal(got, test.want) {
+			t.Errorf("brandVariables(%q, %q) = %v, want %v", test.tenant, test.sender, got, test.want)
+		}
+	}
+
+	// Overrides must not leak into the defaults
+	if brand.Default["color"] != "blue" {
+		t.Errorf("defaults were modified: %v", brand.Default)
+	}
+}
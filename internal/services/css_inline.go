@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/aymerick/douceur/css"
+	"github.com/aymerick/douceur/parser"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// structuralPseudoClass matches the pseudo-classes that depend only on the document, so rules using them can be inlined.
+// Rules with any other pseudo-class or pseudo-element, such as :hover or ::before, stay in the stylesheet.
+var structuralPseudoClass = regexp.MustCompile(`:(?:(?:first|last|only)-(?:child|of-type)|nth-(?:last-)?(?:child|of-type)\([^)]*\)|not\([^():]*\)|empty|root)`)
+
+// cssMatch is a stylesheet rule that applies to an element
+type cssMatch struct {
+	specificity  cascadia.Specificity
+	order        int
+	declarations []*css.Declaration
+}
+
+// inlineCSS moves the rules of <style> elements into the style attributes of the elements they match,
+// since Gmail and Outlook drop or mangle stylesheets. Rules that cannot be inlined, such as @media queries,
+// @font-face and selectors with pseudo-classes like :hover, stay in their <style> element for clients
+// that support them. A <style data-inline="false"> element is left untouched.
+func inlineCSS(document string) (string, error) {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+
+	var styles []*html.Node
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style && nodeAttribute(n, "data-inline") != "false" {
+			styles = append(styles, n)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(root)
+	if len(styles) == 0 {
+		return document, nil
+	}
+
+	matches := make(map[*html.Node][]cssMatch)
+	var matched []*html.Node
+	order := 0
+
+	for _, style := range styles {
+		var text strings.Builder
+		for child := style.FirstChild; child != nil; child = child.NextSibling {
+			text.WriteString(child.Data)
+		}
+		sheet, err := parser.Parse(text.String())
+		if err != nil {
+			return "", err
+		}
+
+		var kept []string
+		for _, rule := range sheet.Rules {
+			if rule.Kind != css.QualifiedRule {
+				kept = append(kept, rule.String())
+				continue
+			}
+
+			var keptSelectors []string
+			for _, selector := range rule.Selectors {
+				if strings.Contains(structuralPseudoClass.ReplaceAllString(selector, ""), ":") {
+					keptSelectors = append(keptSelectors, selector)
+					continue
+				}
+				sel, err := cascadia.Parse(selector)
+				if err != nil {
+					keptSelectors = append(keptSelectors, selector)
+					continue
+				}
+				for _, n := range cascadia.QueryAll(root, sel) {
+					if _, ok := matches[n]; !ok {
+						matched = append(matched, n)
+					}
+					matches[n] = append(matches[n], cssMatch{specificity: sel.Specificity(), order: order, declarations: rule.Declarations})
+				}
+				order++
+			}
+
+			if len(keptSelectors) > 0 {
+				rule.Selectors = keptSelectors
+				kept = append(kept, rule.String())
+			}
+		}
+
+		for style.FirstChild != nil {
+			style.RemoveChild(style.FirstChild)
+		}
+		if len(kept) == 0 {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: "\n" + strings.Join(kept, "\n") + "\n"})
+	}
+
+	for _, n := range matched {
+		inlineDeclarations(n, matches[n])
+	}
+
+	var out bytes.Buffer
+	if err := html.Render(&out, root); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// inlineDeclarations writes the cascaded declarations of the matching rules into the element's style attribute.
+// Existing inline declarations beat normal stylesheet declarations, !important stylesheet declarations beat both
+// and keep their !important. Rules left in the stylesheet, such as @media queries, therefore only override
+// the inlined styles with !important declarations, as they would for inline styles written by hand.
+func inlineDeclarations(n *html.Node, rules []cssMatch) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].specificity != rules[j].specificity {
+			return rules[i].specificity.Less(rules[j].specificity)
+		}
+		return rules[i].order < rules[j].order
+	})
+
+	// The parser drops the value of a last declaration that is not terminated by a semicolon
+	inline, _ := parser.ParseDeclarations(nodeAttribute(n, "style") + ";")
+
+	var properties []string
+	values := make(map[string]*css.Declaration)
+	apply := func(declarations []*css.Declaration, important bool) {
+		for _, declaration := range declarations {
+			if declaration.Important != important {
+				continue
+			}
+			if _, ok := values[declaration.Property]; !ok {
+				properties = append(properties, declaration.Property)
+			}
+			values[declaration.Property] = declaration
+		}
+	}
+
+	for _, important := range []bool{false, true} {
+		for _, rule := range rules {
+			apply(rule.declarations, important)
+		}
+		apply(inline, important)
+	}
+
+	declarations := make([]string, len(properties))
+	for i, property := range properties {
+		declarations[i] = property + ": " + values[property].Value
+		if values[property].Important {
+			declarations[i] += " !important"
+		}
+	}
+	setAttribute(n, "style", strings.Join(declarations, "; "))
+}
+
+// nodeAttribute returns the value of an element's attribute, or "" if it is not set
+func nodeAttribute(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// setAttribute sets or replaces an attribute of an element
+func setAttribute(n *html.Node, key, value string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
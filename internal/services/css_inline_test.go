@@ -0,0 +1,125 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInlineCSS(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "specificity order",
+			document: `<style>#x { color: green } .a { color: blue } p { color: red } p.a { font-weight: bold }</style><p id="x" class="a">t</p><p class="a">u</p><p>v</p>`,
+			contains: []string{
+				`<p id="x" class="a" style="color: green; font-weight: bold">t</p>`,
+				`<p class="a" style="color: blue; font-weight: bold">u</p>`,
+				`<p style="color: red">v</p>`,
+			},
+			excludes: []string{"<style>"},
+		},
+		{
+			name:     "later rule wins at equal specificity",
+			document: `<style>.a { color: red } .b { color: blue }</style><p class="b a">t</p>`,
+			contains: []string{`<p class="b a" style="color: blue">t</p>`},
+		},
+		{
+			name:     "inline style beats stylesheet",
+			document: `<style>#x { color: red; margin: 0 }</style><p id="x" style="color: blue">t</p>`,
+			contains: []string{`<p id="x" style="color: blue; margin: 0">t</p>`},
+		},
+		{
+			name:     "important stylesheet declaration beats inline style and stays important",
+			document: `<style>p { color: red !important; margin: 0 }</style><p style="color: blue; margin: 4px">t</p>`,
+			contains: []string{`<p style="margin: 4px; color: red !important">t</p>`},
+		},
+		{
+			name:     "important inline declaration beats important stylesheet declaration",
+			document: `<style>#x { color: red !important }</style><p id="x" style="color: blue !important">t</p>`,
+			contains: []string{`<p id="x" style="color: blue !important">t</p>`},
+		},
+		{
+			name:     "structural pseudo-classes are inlined",
+			document: `<style>li:first-child { margin: 0 } li:nth-child(2n) { color: red } li:not(.skip) { padding: 1px }</style><ul><li>1</li><li class="skip">2</li></ul>`,
+			contains: []string{
+				`<li style="margin: 0; padding: 1px">1</li>`,
+				`<li class="skip" style="color: red">2</li>`,
+			},
+			excludes: []string{"<style>"},
+		},
+		{
+			name:     "dynamic pseudo-classes and pseudo-elements stay in the stylesheet",
+			document: `<style>a:hover { color: red } a::before { content: "x" } a { color: blue }</style><a href="#">x</a>`,
+			contains: []string{
+				"a:hover {",
+				"a::before {",
+				`<a href="#" style="color: blue">x</a>`,
+			},
+		},
+		{
+			name:     "only the selectors that cannot be inlined are kept",
+			document: `<style>a:hover, a.button { color: red }</style><a class="button">x</a>`,
+			contains: []string{"a:hover {", `<a class="button" style="color: red">x</a>`},
+			excludes: []string{"a.button {"},
+		},
+		{
+			name:     "media queries stay in the stylesheet",
+			document: `<style>@media (max-width: 600px) { p { color: red !important } } p { color: blue }</style><p>t</p>`,
+			contains: []string{
+				"@media (max-width: 600px) {",
+				"color: red !important;",
+				`<p style="color: blue">t</p>`,
+			},
+		},
+		{
+			name:     "font faces stay in the stylesheet",
+			document: `<style>@font-face { font-family: "Brand"; src: url(brand.woff) } p { font-family: "Brand" }</style><p>t</p>`,
+			contains: []string{"@font-face {", `<p style="font-family: &#34;Brand&#34;">t</p>`},
+		},
+		{
+			name:     "inline style without trailing semicolon",
+			document: `<style>p { margin: 0 }</style><p style="color: blue">t</p>`,
+			contains: []string{`<p style="margin: 0; color: blue">t</p>`},
+		},
+		{
+			name:     "opted out stylesheets are untouched",
+			document: `<style data-inline="false">p { color: red }</style><p>t</p>`,
+			contains: []string{`<style data-inline="false">p { color: red }</style><p>t</p>`},
+			excludes: []string{"style=\"color"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inlineCSS(tt.document)
+			if err != nil {
+				t.Fatalf("inlineCSS: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("output contains %q:\n%s", unwanted, got)
+				}
+			}
+		})
+	}
+}
+
+func TestInlineCSSWithoutStylesheet(t *testing.T) {
+	document := `<p style="color: blue">t</p>`
+	got, err := inlineCSS(document)
+	if err != nil {
+		t.Fatalf("inlineCSS: %v", err)
+	}
+	if got != document {
+		t.Fatalf("inlineCSS changed a document without stylesheet: %q", got)
+	}
+}
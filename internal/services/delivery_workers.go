@@ -0,0 +1,188 @@
+package services
+
+import (
+	stdErrors "errors"
+	"log"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-utils/config"
+)
+
+var (
+	deliveryJobs chan uuid.UUID
+	deliveryQuit chan struct{}
+	deliveryWg   sync.WaitGroup
+)
+
+// StartDeliveryWorkers starts a bounded pool of workers that deliver queued mails,
+// a recovery loop that picks up mails left over from a restart or a full pool,
+// and the scheduler that queues mails once their sendAt is due
+func StartDeliveryWorkers() {
+	workers := config.GetEnvInt("MAIL_WORKERS", 4)
+	queueSize := config.GetEnvInt("MAIL_WORKER_QUEUE_SIZE", 1000)
+
+	deliveryJobs = make(chan uuid.UUID, queueSize)
+	deliveryQuit = make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		deliveryWg.Add(1)
+		go deliveryWorker()
+	}
+
+	deliveryWg.Add(1)
+	go recoveryLoop()
+
+	deliveryWg.Add(1)
+	go schedulerLoop()
+
+	log.Printf("Started %d delivery workers (queue size %d)", workers, queueSize)
+}
+
+// StopDeliveryWorkers stops accepting new jobs and waits for in-flight deliveries to finish.
+// Mails still waiting in the in-memory queue stay queued in the database and are recovered on next start.
+func StopDeliveryWorkers() {
+	if deliveryQuit == nil {
+		return
+	}
+	close(deliveryQuit)
+	deliveryWg.Wait()
+}
+
+// enqueueDelivery hands a queued mail to the worker pool without blocking.
+// When the pool is saturated the mail is left for the recovery loop.
+func enqueueDelivery(id uuid.UUID) {
+	if deliveryJobs == nil {
+		log.Printf("Delivery workers not running, mail %s left queued", id)
+		return
+	}
+
+	select {
+	case deliveryJobs <- id:
+	default:
+		log.Printf("Delivery queue full, mail %s left for recovery", id)
+	}
+}
+
+func deliveryWorker() {
+	defer deliveryWg.Done()
+
+	for {
+		select {
+		case <-deliveryQuit:
+			return
+		case id := <-deliveryJobs:
+			deliverQueuedMail(id)
+		}
+	}
+}
+
+// deliverQueuedMail claims a queued mail and delivers it. The conditional update makes sure
+// only one worker (across all replicas) sends a given mail.
+func deliverQueuedMail(id uuid.UUID) {
+	claimed, err := claimMail(id, models.MailStatusQueued)
+	if err != nil {
+		log.Printf("Failed to claim mail %s: %v", id, err)
+		return
+	}
+	if !claimed {
+		// Already claimed by another worker or no longer queued
+		return
+	}
+
+	var mail models.Mail
+	if err := database.DB.Preload("Attachments.AttachmentFile").Preload("Recipients").Where("id = ?", id).First(&mail).Error; err != nil {
+		log.Printf("Failed to load mail %s: %v", id, err)
+		return
+	}
+
+	if err := DeliverMail(&mail); err != nil {
+		log.Printf("Failed to update mail status for %s: %v", id, err)
+		return
+	}
+
+	log.Printf("Mail %s delivered with status %s", id, mail.Status)
+}
+
+// claimMail moves a mail from status to "sending", reporting false when it no longer has that status.
+// Deliveries renew the claim before every recipient, see renewMailClaim.
+func claimMail(id uuid.UUID, status string) (bool, error) {
+	result := database.DB.Model(&models.Mail{}).
+		Where("id = ? AND status = ?", id, status).
+		Update("status", models.MailStatusSending)
+	return result.RowsAffected > 0, result.Error
+}
+
+// errMailReclaimed stops a delivery whose mail was requeued by recovery in the meantime
+var errMailReclaimed = stdErrors.New("mail was requeued during delivery")
+
+// renewMailClaim touches the updated_at of a mail being sent, failing with errMailReclaimed if it was requeued
+func renewMailClaim(id uuid.UUID) error {
+	result := database.DB.Model(&models.Mail{}).
+		Where("id = ? AND status = ?", id, models.MailStatusSending).
+		Update("updated_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errMailReclaimed
+	}
+	return nil
+}
+
+func recoveryLoop() {
+	defer deliveryWg.Done()
+
+	interval := time.Duration(config.GetEnvInt("MAIL_RECOVERY_INTERVAL", 30)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// On startup everything still queued is ours to pick up
+	recoverMails(0)
+
+	for {
+		select {
+		case <-deliveryQuit:
+			return
+		case <-ticker.C:
+			recoverMails(interval)
+		}
+	}
+}
+
+// recoverMails requeues stale "sending" and "pending" mails and enqueues queued mails untouched for longer than grace
+func recoverMails(grace time.Duration) {
+	sendingTimeout := time.Duration(config.GetEnvInt("MAIL_SENDING_TIMEOUT", 600)) * time.Second
+
+	if err := database.DB.Model(&models.Mail{}).
+		Where("status IN ? AND updated_at < ?", []string{models.MailStatusSending, models.MailStatusPending}, time.Now().Add(-sendingTimeout)).
+		Update("status", models.MailStatusQueued).Error; err != nil {
+		log.Printf("Failed to requeue stale mails: %v", err)
+	}
+
+	capacity := cap(deliveryJobs) - len(deliveryJobs)
+	if capacity <= 0 {
+		return
+	}
+
+	var ids []uuid.UUID
+	if err := database.DB.Model(&models.Mail{}).
+		Where("status = ? AND updated_at <= ?", models.MailStatusQueued, time.Now().Add(-grace)).
+		Order("created_at ASC").
+		Limit(capacity).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("Failed to load queued mails: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		enqueueDelivery(id)
+	}
+
+	if len(ids) > 0 {
+		log.Printf("Recovered %d queued mails", len(ids))
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockElements start on a new line in the plain-text version
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true, "dl": true, "dt": true, "dd": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "tr": true, "ul": true,
+}
+
+// skippedElements have no readable content
+var skippedElements = map[string]bool{"head": true, "script": true, "style": true, "title": true}
+
+var (
+	horizontalSpace = regexp.MustCompile(`[ \t\r\f\v]+`)
+	extraNewlines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText derives a plain-text body from rendered HTML for mails whose template has no text version.
+// Block elements become paragraphs, list items are bulleted and links keep their URL after the link text.
+func htmlToText(content string) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	skipDepth := 0
+	var links []string
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skippedElements[token.Data] {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			switch {
+			case token.Data == "br":
+				b.WriteString("\n")
+			case token.Data == "li":
+				b.WriteString("\n- ")
+			case token.Data == "td" || token.Data == "th":
+				b.WriteString(" ")
+			case token.Data == "a":
+				links = append(links, attribute(token, "href"))
+			case blockElements[token.Data]:
+				b.WriteString("\n\n")
+			}
+		case html.EndTagToken:
+			if skippedElements[token.Data] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			switch {
+			case token.Data == "a" && len(links) > 0:
+				href := links[len(links)-1]
+				links = links[:len(links)-1]
+				if href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(href, "mailto:") {
+					b.WriteString(" (" + href + ")")
+				}
+			case blockElements[token.Data]:
+				b.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if skipDepth == 0 {
+				b.WriteString(strings.ReplaceAll(token.Data, "\n", " "))
+			}
+		}
+	}
+
+	// Collapse the whitespace left over from the HTML source
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(horizontalSpace.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(extraNewlines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func attribute(token html.Token, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package services
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "plain text", html: "Hello", want: "Hello"},
+		{name: "paragraphs", html: "<p>First</p><p>Second</p>", want: "First\n\nSecond"},
+		{name: "line break", html: "a<br>b<br/>c", want: "a\nb\nc"},
+		{name: "list items", html: "<ul><li>one</li><li>two</li></ul>", want: "- one\n- two"},
+		{name: "table cells", html: "<table><tr><td>Total</td><td>5</td></tr></table>", want: "Total 5"},
+		{name: "link keeps its URL", html: `<a href="https://example.com">Open</a>`, want: "Open (https://example.com)"},
+		{name: "anchor and mailto links", html: `<a href="#top">Top</a> <a href="mailto:a@example.com">Mail</a>`, want: "Top Mail"},
+		{name: "nested links", html: `<a href="https://a.example"><a href="https://b.example">x</a></a>`, want: "x (https://b.example) (https://a.example)"},
+		{name: "skipped elements", html: "<head><title>T</title><style>p{}</style></head><script>x()</script><p>Body</p>", want: "Body"},
+		{name: "source whitespace", html: "<div>\n  Hello\n\t  world  \n</div>", want: "Hello world"},
+		{name: "entities", html: "<p>Tom &amp; Jerry &lt;3</p>", want: "Tom & Jerry <3"},
+		{name: "collapsed blank lines", html: "<div><div><p>a</p></div></div><h1>b</h1>", want: "a\n\nb"},
+	}
+	for _, test := range tests {
+		if got := htmlToText(test.html); got != test.want {
+			t.Errorf("%s: htmlToText(%q) = %q, want %q", test.name, test.html, got, test.want)
+		}
+	}
+}
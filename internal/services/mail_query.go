@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"mailer-api/internal/requests"
+	"time"
+)
+
+// MailList is a single page of mails with the cursor for the next page
+type MailList struct {
+	Items      []models.Mail `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// ListMails returns one page of mails matching the query, ordered by (created_at, id).
+// The query must have been validated beforehand.
+func ListMails(query requests.MailListQuery) (*MailList, error) {
+	db := database.DB.Model(&models.Mail{})
+
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.To != "" {
+		// Match the address in any of the recipient lists
+		address, _ := json.Marshal([]string{query.To})
+		db = db.Where("(to_addresses @> ? OR cc_addresses @> ? OR bcc_addresses @> ?)", string(address), string(address), string(address))
+	}
+	if query.Template != "" {
+		db = db.Where("template = ?", query.Template)
+	}
+	if query.CreatedAfter != "" {
+		createdAfter, _ := time.Parse(time.RFC3339, query.CreatedAfter)
+		db = db.Where("created_at >= ?", createdAfter)
+	}
+	if query.CreatedBefore != "" {
+		createdBefore, _ := time.Parse(time.RFC3339, query.CreatedBefore)
+		db = db.Where("created_at < ?", createdBefore)
+	}
+
+	// Keyset pagination: continue strictly after the last row of the previous page
+	if query.Cursor != "" {
+		cursor, err := requests.DecodeMailCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if query.Sort == "asc" {
+			db = db.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID)
+		} else {
+			db = db.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+		}
+	}
+
+	if query.Sort == "asc" {
+		db = db.Order("created_at ASC, id ASC")
+	} else {
+		db = db.Order("created_at DESC, id DESC")
+	}
+
+	// Fetch one extra row to know whether another page exists
+	var mails []models.Mail
+	if err := db.Limit(query.Limit + 1).Find(&mails).Error; err != nil {
+		return nil, err
+	}
+
+	list := &MailList{Items: mails}
+	if len(mails) > query.Limit {
+		list.Items = mails[:query.Limit]
+		last := list.Items[len(list.Items)-1]
+		list.NextCursor = requests.EncodeMailCursor(requests.MailCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return list, nil
+}
@@ -1,18 +1,18 @@
 package services
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"html/template"
+	stdErrors "errors"
 	"log"
 	"mailer-api/internal/database"
 	"mailer-api/internal/models"
 	"mailer-api/internal/requests"
-	"os"
-	"path/filepath"
+	netmail "net/mail"
 	"strconv"
+	"time"
 
 	"github.com/kerimovok/go-pkg-database/sql"
 	"github.com/kerimovok/go-pkg-utils/config"
@@ -27,7 +27,7 @@ var (
 	smtpUsername string
 	smtpPassword string
 	smtpFrom     string
-	dialer       *gomail.Dialer
+	dialer       *smtpDialer
 )
 
 func InitMailService() {
@@ -38,136 +38,300 @@ func InitMailService() {
 	smtpFrom = config.GetEnv("SMTP_FROM")
 
 	portInt, _ := strconv.Atoi(smtpPort)
-	dialer = gomail.NewDialer(smtpHost, portInt, smtpUsername, smtpPassword)
-	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	dialer = &smtpDialer{
+		host:      smtpHost,
+		port:      portInt,
+		username:  smtpUsername,
+		password:  smtpPassword,
+		ssl:       portInt == 465,
+		tlsConfig: &tls.Config{InsecureSkipVerify: true},
+		timeout:   time.Duration(config.GetEnvInt("SMTP_TIMEOUT", 60)) * time.Second,
+	}
 }
 
-func createTemplateFuncMap() template.FuncMap {
-	return template.FuncMap{
-		"safeURL": func(s string) template.URL {
-			return template.URL(s)
-		},
+// mailSender returns the From address of a mail: its own sender overrides the template's, which overrides SMTP_FROM
+func mailSender(from string, tmpl *mailTemplate) (*netmail.Address, error) {
+	if from == "" {
+		from = tmpl.From
+	}
+	if from == "" {
+		// SMTP_FROM is a display name and may contain specials such as commas, so it is not parsed
+		return &netmail.Address{Name: smtpFrom, Address: smtpUsername}, nil
 	}
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, errors.ValidationError("INVALID_SENDER", "Sender is not a valid address").
+			WithMetadata("from", from).
+			WithMetadata("error", err.Error())
+	}
+	return sender, nil
 }
 
-// ProcessEmailRequest handles the complete email processing workflow
-func ProcessEmailRequest(to, subject, templateName string, data map[string]interface{}, attachments []requests.AttachmentRequest) (*models.Mail, error) {
-	// Create mail record
-	mail := models.Mail{
-		To:       to,
-		Subject:  subject,
-		Template: templateName,
-		Data:     sql.JSONB(data),
-		Status:   "pending",
+// CreateMail persists a mail and its attachment records with the given status.
+// When the input carries an idempotency key that was already used, the original mail is returned
+// with created set to false, or IDEMPOTENCY_KEY_REUSED if the payload differs.
+func CreateMail(input requests.MailRequest, status string) (mail *models.Mail, created bool, err error) {
+	requestHash, err := hashMailRequest(input)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// Use WithTransaction helper
-	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
-		// Create mail record
-		if err := tx.Create(&mail).Error; err != nil {
-			return err
+	if input.IdempotencyKey != "" {
+		existing, err := findIdempotentMail(input.IdempotencyKey, requestHash)
+		if err != nil || existing != nil {
+			return existing, false, err
 		}
+	}
 
-		// Create attachment records
-		for _, attachment := range attachments {
-			att := models.Attachment{
-				MailID: mail.ID,
-				File:   attachment.File,
-			}
-			if err := tx.Create(&att).Error; err != nil {
-				return err
+	attachments, stored, err := resolveAttachments(input.Attachments)
+	if err != nil {
+		return nil, false, err
+	}
+
+	newMail := newMailRecord(input, status)
+	newMail.RequestHash = requestHash
+	if input.IdempotencyKey != "" {
+		newMail.IdempotencyKey = &input.IdempotencyKey
+	}
+
+	// Use WithTransaction helper
+	err = sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		return insertMail(tx, &newMail, attachments)
+	})
+
+	if err != nil {
+		// The inline attachments stored for this request belong to no mail
+		discardAttachments(stored)
+
+		// A concurrent request with the same key won the insert
+		if input.IdempotencyKey != "" && stdErrors.Is(err, gorm.ErrDuplicatedKey) {
+			existing, findErr := findIdempotentMail(input.IdempotencyKey, requestHash)
+			if findErr != nil || existing != nil {
+				return existing, false, findErr
 			}
 		}
-		return nil
-	})
+		return nil, false, err
+	}
+
+	return &newMail, true, nil
+}
+
+// newMailRecord maps a mail request onto an unsaved mail record.
+// Mails with a future sendAt are stored as scheduled regardless of the requested status.
+func newMailRecord(input requests.MailRequest, status string) models.Mail {
+	if input.SendAt != nil && input.SendAt.After(time.Now()) {
+		status = models.MailStatusScheduled
+	}
+
+	return models.Mail{
+		To:       models.StringList(input.To),
+		Cc:       models.StringList(input.Cc),
+		Bcc:      models.StringList(input.Bcc),
+		ReplyTo:  models.StringList(input.ReplyTo),
+		Subject:  input.Subject,
+		Template: input.Template,
+		Data:     sql.JSONB(input.Data),
+		Status:   status,
+		SendAt:   input.SendAt,
+		Locale:   input.Locale,
+		From:     input.From,
+		Category: input.Category,
+		Tenant:   input.Tenant,
+	}
+}
+
+// insertMail creates the mail record with its recipient and attachment records within tx.
+// Attachments are unsaved records as returned by resolveAttachments.
+func insertMail(tx *gorm.DB, mail *models.Mail, attachments []models.Attachment) error {
+	// Create mail record together with its recipients
+	mail.Recipients = newMailRecipients(mail)
+	if err := tx.Create(mail).Error; err != nil {
+		return err
+	}
+
+	// Create attachment records
+	for _, attachment := range attachments {
+		att := models.Attachment{
+			MailID:           mail.ID,
+			File:             attachment.File,
+			AttachmentFileID: attachment.AttachmentFileID,
+			AttachmentFile:   attachment.AttachmentFile,
+		}
+		if err := tx.Omit("AttachmentFile").Create(&att).Error; err != nil {
+			return err
+		}
+		mail.Attachments = append(mail.Attachments, att)
+	}
+	return nil
+}
 
+// findIdempotentMail returns the mail previously created with the key, or nil if there is none
+func findIdempotentMail(key, requestHash string) (*models.Mail, error) {
+	var mail models.Mail
+	err := database.DB.Preload("Attachments.AttachmentFile").Where("idempotency_key = ?", key).First(&mail).Error
+	if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert data to string for SendMail
-	dataStr, err := json.Marshal(data)
+	if mail.RequestHash != requestHash {
+		return nil, errors.ConflictError("IDEMPOTENCY_KEY_REUSED", "Idempotency key was already used with a different request").
+			WithMetadata("idempotencyKey", key)
+	}
+
+	return &mail, nil
+}
+
+// hashMailRequest fingerprints the request payload; map keys are marshalled in sorted order
+func hashMailRequest(input requests.MailRequest) (string, error) {
+	payload, err := json.Marshal(input)
 	if err != nil {
-		mail.Status = "failed"
-		mail.Error = err.Error()
-		if saveErr := database.DB.Save(&mail).Error; saveErr != nil {
-			log.Printf("Failed to save failed mail status: %v", saveErr)
-		}
-		return nil, err
+		return "", err
 	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-	// Send the email
-	err = SendMail(to, subject, templateName, string(dataStr), attachments)
+// DeliverMail renders and sends a stored mail, then records the outcome on the mail record.
+// Delivery failures are stored on the mail; only an error to persist the outcome is returned.
+func DeliverMail(mail *models.Mail) error {
+	err := SendMail(mail)
+	if stdErrors.Is(err, errMailReclaimed) {
+		// Recovery requeued the mail and another worker owns it now, its outcome is theirs to record
+		log.Printf("Mail %s was claimed by another worker during delivery", mail.ID)
+		return nil
+	}
 	if err != nil {
-		mail.Status = "failed"
-		mail.Error = err.Error()
-	} else {
-		mail.Status = "sent"
+		// Nothing was handed to the SMTP server for the recipients still pending
+		markPendingRecipientsFailed(mail, err)
+	}
+	mail.Status, mail.Error = mailOutcome(mail, err)
+	mail.ErrorCode = ""
+	// A partially sent mail keeps the send error only as its error text
+	if mailErr, ok := err.(*errors.Error); ok && mail.Status == models.MailStatusFailed {
+		mail.ErrorCode = mailErr.Code
 	}
 
 	// Update mail status
-	if err := database.DB.Save(&mail).Error; err != nil {
+	return database.DB.Model(mail).Updates(map[string]interface{}{
+		"status":              mail.Status,
+		"error":               mail.Error,
+		"error_code":          mail.ErrorCode,
+		"template_version":    mail.TemplateVersion,
+		"template_version_id": mail.TemplateVersionID,
+		"category":            mail.Category,
+	}).Error
+}
+
+// ProcessEmailRequest handles the complete email processing workflow synchronously.
+// A replayed idempotency key returns the original mail without sending it again,
+// and mails scheduled for later are left to the scheduler.
+func ProcessEmailRequest(input requests.MailRequest) (*models.Mail, bool, error) {
+	mail, created, err := CreateMail(input, models.MailStatusPending)
+	if err != nil || !created || mail.Status == models.MailStatusScheduled {
+		return mail, created, err
+	}
+
+	// Claim the mail like the workers do, so recovery can tell a stalled delivery from a live one
+	claimed, err := claimMail(mail.ID, models.MailStatusPending)
+	if err != nil || !claimed {
+		return mail, true, err
+	}
+	mail.Status = models.MailStatusSending
+
+	if err := DeliverMail(mail); err != nil {
 		log.Printf("Failed to update mail status: %v", err)
 	}
 
-	return &mail, nil
+	return mail, true, nil
+}
+
+// QueueEmailRequest persists the mail as queued and hands it to the delivery workers.
+// A replayed idempotency key returns the original mail without queueing it again,
+// and mails scheduled for later are left to the scheduler.
+func QueueEmailRequest(input requests.MailRequest) (*models.Mail, bool, error) {
+	mail, created, err := CreateMail(input, models.MailStatusQueued)
+	if err != nil || !created || mail.Status == models.MailStatusScheduled {
+		return mail, created, err
+	}
+
+	enqueueDelivery(mail.ID)
+
+	return mail, true, nil
 }
 
-func SendMail(to, subject, templateName string, data string, attachments []requests.AttachmentRequest) error {
+// SendMail renders the mail's template with its data and sends it to each pending recipient.
+// Per-recipient outcomes are recorded on mail.Recipients; the returned error covers failures
+// that affect the whole mail, such as rendering or connecting to the SMTP server.
+func SendMail(mail *models.Mail) error {
+	// Round-trip the stored data through JSON so templates see the same types as for a fresh request
 	var templateData map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &templateData); err != nil {
+	data, err := json.Marshal(mail.Data)
+	if err != nil {
+		return errors.InternalError("MARSHAL_DATA", "Failed to marshal template data").WithMetadata("error", err.Error())
+	}
+	if err := json.Unmarshal(data, &templateData); err != nil {
 		return errors.InternalError("UNMARSHAL_DATA", "Failed to unmarshal template data").WithMetadata("error", err.Error())
 	}
 
-	// Parse subject as template
-	subjectTmpl, err := template.New("subject").Parse(subject)
+	tmpl, err := resolveTemplate(mail.Template, mail.Locale)
 	if err != nil {
-		return errors.InternalError("PARSE_SUBJECT", "Failed to parse subject template").WithMetadata("error", err.Error())
+		return err
 	}
-
-	var parsedSubject bytes.Buffer
-	if err := subjectTmpl.Execute(&parsedSubject, templateData); err != nil {
-		return errors.InternalError("EXECUTE_SUBJECT", "Failed to execute subject template").WithMetadata("error", err.Error())
+	if mail.Subject == "" && !tmpl.hasSubject() {
+		// Submission rejects these mails, but the template may have changed since
+		return errors.ValidationError("MISSING_SUBJECT", "Mail has no subject and its template has no default subject").
+			WithMetadata("template", mail.Template)
 	}
-
-	// Use absolute path for template
-	templatePath := filepath.Join("templates", templateName+".html")
-	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
-		log.Printf("Template file not found: %s", templatePath)
-		return errors.NotFoundError("TEMPLATE_NOT_FOUND", "Template file not found").WithMetadata("template", templateName)
+	sender, err := mailSender(mail.From, tmpl)
+	if err != nil {
+		return err
 	}
 
-	// Create template with function map
-	tmpl, err := template.New(templateName + ".html").
-		Funcs(createTemplateFuncMap()).
-		ParseFiles(templatePath)
+	// Brand variables of the tenant and sender are available to subject and body as .Brand
+	rendered, err := renderMailTemplate(tmpl, mail.Locale, mail.Subject, withBrand(templateData, mail.Tenant, sender.Address))
 	if err != nil {
-		return errors.InternalError("PARSE_TEMPLATE", "Failed to parse template").WithMetadata("error", err.Error())
+		return err
 	}
-
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, templateData); err != nil {
-		return errors.InternalError("EXECUTE_TEMPLATE", "Failed to execute template").WithMetadata("error", err.Error())
+	if rendered.Version > 0 {
+		mail.TemplateVersion = &rendered.Version
+		mail.TemplateVersionID = rendered.VersionID
+	}
+	if mail.Category == "" {
+		mail.Category = rendered.Category
 	}
 
 	m := gomail.NewMessage()
-	m.SetHeader("From", fmt.Sprintf("%s <%s>", smtpFrom, smtpUsername))
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", parsedSubject.String())
-	m.SetBody("text/html", body.String())
+	// SetAddressHeader encodes a non-ASCII display name on its own, SetHeader would encode the address too
+	m.SetAddressHeader("From", sender.Address, sender.Name)
+	m.SetHeader("To", mail.To...)
+	if len(mail.Cc) > 0 {
+		m.SetHeader("Cc", mail.Cc...)
+	}
+	if len(mail.Bcc) > 0 {
+		m.SetHeader("Bcc", mail.Bcc...)
+	}
+	if len(mail.ReplyTo) > 0 {
+		m.SetHeader("Reply-To", mail.ReplyTo...)
+	}
+	m.SetHeader("Subject", rendered.Subject)
+	// multipart/alternative lists the preferred HTML part last
+	m.SetBody("text/plain", rendered.Text)
+	m.AddAlternative("text/html", rendered.HTML)
 
-	// Process attachments
-	for _, attachment := range attachments {
-		attachPath := filepath.Join("attachments", attachment.File)
-		if _, err := os.Stat(attachPath); os.IsNotExist(err) {
-			return errors.NotFoundError("ATTACHMENT_NOT_FOUND", "Attachment file not found").WithMetadata("file", attachment.File)
-		}
-		m.Attach(attachPath)
+	// Images referenced through {{embed}} become inline parts of a multipart/related body
+	if err := embedAssets(m, rendered.Embeds); err != nil {
+		return err
 	}
 
-	if err := dialer.DialAndSend(m); err != nil {
-		return errors.InternalError("SEND_EMAIL", "Failed to send email").WithMetadata("error", err.Error())
+	// Process attachments
+	for _, attachment := range mail.Attachments {
+		if err := attachToMessage(m, attachment); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return deliverToRecipients(m, mail)
 }
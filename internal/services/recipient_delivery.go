@@ -0,0 +1,149 @@
+package services
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"log"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"time"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gopkg.in/gomail.v2"
+)
+
+// newMailRecipients creates one pending recipient record per To, Cc and Bcc address
+func newMailRecipients(mail *models.Mail) []models.MailRecipient {
+	var recipients []models.MailRecipient
+	for _, list := range []struct {
+		kind      string
+		addresses models.StringList
+	}{
+		{models.RecipientKindTo, mail.To},
+		{models.RecipientKindCc, mail.Cc},
+		{models.RecipientKindBcc, mail.Bcc},
+	} {
+		for _, address := range list.addresses {
+			recipients = append(recipients, models.MailRecipient{
+				MailID:  mail.ID,
+				Address: address,
+				Kind:    list.kind,
+				Status:  models.RecipientStatusPending,
+			})
+		}
+	}
+	return recipients
+}
+
+// deliverToRecipients sends the message to each pending recipient in its own SMTP transaction
+// and records each outcome, renewing the claim on the mail before every recipient
+func deliverToRecipients(m *gomail.Message, mail *models.Mail) error {
+	// Mails created before per-recipient tracking have no recipient records yet
+	if len(mail.Recipients) == 0 {
+		mail.Recipients = newMailRecipients(mail)
+		if err := database.DB.Create(&mail.Recipients).Error; err != nil {
+			return errors.InternalError("CREATE_RECIPIENTS", "Failed to create recipient records").WithMetadata("error", err.Error())
+		}
+	}
+
+	var sender gomail.SendCloser
+	defer func() {
+		if sender != nil {
+			sender.Close()
+		}
+	}()
+
+	for i := range mail.Recipients {
+		recipient := &mail.Recipients[i]
+		if recipient.Status != models.RecipientStatusPending {
+			continue
+		}
+
+		// Stop before sending to anyone else once recovery handed the mail to another worker
+		if err := renewMailClaim(mail.ID); stdErrors.Is(err, errMailReclaimed) {
+			return err
+		} else if err != nil {
+			log.Printf("Failed to renew the claim on mail %s: %v", mail.ID, err)
+		}
+
+		if sender == nil {
+			var err error
+			if sender, err = dialer.Dial(); err != nil {
+				return errors.InternalError("SEND_EMAIL", "Failed to connect to SMTP server").WithMetadata("error", err.Error())
+			}
+		}
+
+		now := time.Now()
+		if err := sender.Send(smtpUsername, []string{recipient.Address}, m); err != nil {
+			recipient.Status = models.RecipientStatusFailed
+			recipient.SMTPResponse = err.Error()
+			recipient.FailedAt = &now
+
+			// The session is left mid-transaction after a rejection, start over with a fresh connection
+			sender.Close()
+			sender = nil
+		} else {
+			recipient.Status = models.RecipientStatusSent
+			recipient.SMTPResponse = ""
+			recipient.SentAt = &now
+		}
+
+		saveRecipient(recipient)
+	}
+
+	return nil
+}
+
+// markPendingRecipientsFailed fails the recipients that were never attempted because of a mail-level error
+func markPendingRecipientsFailed(mail *models.Mail, cause error) {
+	now := time.Now()
+	for i := range mail.Recipients {
+		recipient := &mail.Recipients[i]
+		if recipient.Status != models.RecipientStatusPending {
+			continue
+		}
+		recipient.Status = models.RecipientStatusFailed
+		recipient.SMTPResponse = cause.Error()
+		recipient.FailedAt = &now
+		saveRecipient(recipient)
+	}
+}
+
+func saveRecipient(recipient *models.MailRecipient) {
+	if err := database.DB.Model(recipient).Updates(map[string]interface{}{
+		"status":        recipient.Status,
+		"smtp_response": recipient.SMTPResponse,
+		"sent_at":       recipient.SentAt,
+		"failed_at":     recipient.FailedAt,
+	}).Error; err != nil {
+		log.Printf("Failed to update recipient %s of mail %s: %v", recipient.Address, recipient.MailID, err)
+	}
+}
+
+// mailOutcome derives the mail-level status and error from the send error and recipient outcomes.
+// A send error fails the whole mail only when no recipient was sent yet, e.g. when reconnecting after
+// a rejected recipient fails, the mail is partially sent and the send error becomes its error text.
+func mailOutcome(mail *models.Mail, sendErr error) (string, string) {
+	sent, failed := 0, 0
+	for _, recipient := range mail.Recipients {
+		switch recipient.Status {
+		case models.RecipientStatusSent:
+			sent++
+		case models.RecipientStatusFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case sendErr != nil && sent == 0:
+		return models.MailStatusFailed, sendErr.Error()
+	case sendErr != nil:
+		return models.MailStatusPartiallySent, fmt.Sprintf("%d of %d recipients were not sent: %v", len(mail.Recipients)-sent, len(mail.Recipients), sendErr)
+	case failed == 0:
+		return models.MailStatusSent, ""
+	case failed == len(mail.Recipients):
+		return models.MailStatusFailed, "all recipients were rejected"
+	default:
+		return models.MailStatusPartiallySent, fmt.Sprintf("%d of %d recipients were rejected", failed, len(mail.Recipients))
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"mailer-api/internal/models"
+	"testing"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+func TestMailOutcome(t *testing.T) {
+	recipients := func(statuses ...string) *models.Mail {
+		mail := &models.Mail{}
+		for _, status := range statuses {
+			mail.Recipients = append(mail.Recipients, models.MailRecipient{Status: status})
+		}
+		return mail
+	}
+	sent, failed := models.RecipientStatusSent, models.RecipientStatusFailed
+	dialErr := errors.InternalError("SEND_EMAIL", "Failed to connect to SMTP server")
+
+	tests := []struct {
+		name       string
+		mail       *models.Mail
+		sendErr    error
+		wantStatus string
+		wantError  string
+	}{
+		{name: "all sent", mail: recipients(sent, sent), wantStatus: models.MailStatusSent},
+		{name: "all rejected", mail: recipients(failed, failed), wantStatus: models.MailStatusFailed, wantError: "all recipients were rejected"},
+		{name: "some rejected", mail: recipients(sent, failed, sent), wantStatus: models.MailStatusPartiallySent, wantError: "1 of 3 recipients were rejected"},
+		{name: "send error before any recipient", mail: recipients(failed, failed), sendErr: dialErr, wantStatus: models.MailStatusFailed, wantError: dialErr.Error()},
+		{
+			name:       "send error after a sent recipient",
+			mail:       recipients(sent, failed, failed),
+			sendErr:    dialErr,
+			wantStatus: models.MailStatusPartiallySent,
+			wantError:  "2 of 3 recipients were not sent: " + dialErr.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		status, message := mailOutcome(tt.mail, tt.sendErr)
+		if status != tt.wantStatus || message != tt.wantError {
+			t.Errorf("%s: mailOutcome = %q, %q, want %q, %q", tt.name, status, message, tt.wantStatus, tt.wantError)
+		}
+	}
+}
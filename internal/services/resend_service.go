@@ -0,0 +1,73 @@
+package services
+
+import (
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"mailer-api/internal/requests"
+
+	"github.com/kerimovok/go-pkg-database/sql"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gorm.io/gorm"
+)
+
+// PrepareResend loads a finished mail and builds the request for resending it.
+// Overrides replace the To recipients and are merged over the stored data.
+func PrepareResend(id string, overrides requests.ResendMailRequest) (*models.Mail, requests.MailRequest, error) {
+	var original models.Mail
+	if err := database.DB.Preload("Attachments.AttachmentFile").Where("id = ?", id).First(&original).Error; err != nil {
+		return nil, requests.MailRequest{}, errors.NotFoundError("MAIL_NOT_FOUND", "Mail not found").WithMetadata("id", id)
+	}
+
+	if !isFinalMailStatus(original.Status) {
+		return nil, requests.MailRequest{}, errors.ConflictError("MAIL_NOT_FINISHED", "Mail is still being processed").
+			WithMetadata("status", original.Status)
+	}
+
+	// The tenant may have been removed from the brand configuration since the original was sent
+	if errs := ValidateTenant(original.Tenant); errs.HasErrors() {
+		return nil, requests.MailRequest{}, errors.ValidationError("UNKNOWN_TENANT", "Tenant is not configured in the brand configuration").
+			WithMetadata("tenant", original.Tenant)
+	}
+
+	input := requests.MailRequest{
+		To:       requests.AddressList(original.To),
+		Cc:       requests.AddressList(original.Cc),
+		Bcc:      requests.AddressList(original.Bcc),
+		ReplyTo:  requests.AddressList(original.ReplyTo),
+		Subject:  original.Subject,
+		Template: original.Template,
+		Locale:   original.Locale,
+		From:     original.From,
+		Category: original.Category,
+		Tenant:   original.Tenant,
+		Data:     make(map[string]interface{}, len(original.Data)+len(overrides.Data)),
+	}
+	for key, value := range original.Data {
+		input.Data[key] = value
+	}
+	for key, value := range overrides.Data {
+		input.Data[key] = value
+	}
+	if len(overrides.To) > 0 {
+		input.To = overrides.To
+	}
+
+	return &original, input, nil
+}
+
+// ResendMail queues a new delivery attempt of input, prepared by PrepareResend, with the original's attachments
+func ResendMail(original *models.Mail, input requests.MailRequest) (*models.Mail, error) {
+	mail := newMailRecord(input, models.MailStatusQueued)
+	mail.ResentFromID = &original.ID
+
+	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		return insertMail(tx, &mail, original.Attachments)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	enqueueDelivery(mail.ID)
+
+	return &mail, nil
+}
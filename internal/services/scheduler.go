@@ -0,0 +1,94 @@
+package services
+
+import (
+	"log"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-database/sql"
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// schedulerBatchSize limits how many due mails a single tick moves to the worker pool
+const schedulerBatchSize = 100
+
+func schedulerLoop() {
+	defer deliveryWg.Done()
+
+	interval := time.Duration(config.GetEnvInt("MAIL_SCHEDULER_INTERVAL", 10)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deliveryQuit:
+			return
+		case <-ticker.C:
+			dispatchDueMails()
+		}
+	}
+}
+
+// dispatchDueMails moves scheduled mails whose sendAt has passed to queued and hands them to the
+// worker pool. SKIP LOCKED lets several replicas run the scheduler without dispatching a mail twice.
+func dispatchDueMails() {
+	var ids []uuid.UUID
+
+	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Mail{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND send_at <= ?", models.MailStatusScheduled, time.Now()).
+			Order("send_at ASC").
+			Limit(schedulerBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.Mail{}).
+			Where("id IN ?", ids).
+			Update("status", models.MailStatusQueued).Error
+	})
+	if err != nil {
+		log.Printf("Failed to dispatch scheduled mails: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		enqueueDelivery(id)
+	}
+
+	if len(ids) > 0 {
+		log.Printf("Dispatched %d scheduled mails", len(ids))
+	}
+}
+
+// CancelScheduledMail cancels a mail that is still waiting for its sendAt
+func CancelScheduledMail(id string) (*models.Mail, error) {
+	var mail models.Mail
+	if err := database.DB.Where("id = ?", id).First(&mail).Error; err != nil {
+		return nil, errors.NotFoundError("MAIL_NOT_FOUND", "Mail not found").WithMetadata("id", id)
+	}
+
+	// The status condition makes this safe against the scheduler dispatching it concurrently
+	result := database.DB.Model(&mail).
+		Where("status = ?", models.MailStatusScheduled).
+		Update("status", models.MailStatusCancelled)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.ConflictError("MAIL_NOT_SCHEDULED", "Only scheduled mails that have not been sent can be cancelled").
+			WithMetadata("status", mail.Status)
+	}
+
+	return &mail, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// smtpDialer connects and authenticates to the SMTP server like gomail.Dialer, but with a timeout on
+// connecting and on every read and write, so a hung relay fails the delivery instead of blocking a worker
+// past MAIL_SENDING_TIMEOUT, after which the mail would be claimed and sent again
+type smtpDialer struct {
+	host      string
+	port      int
+	username  string
+	password  string
+	ssl       bool
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+func (d *smtpDialer) Dial() (gomail.SendCloser, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(d.host, strconv.Itoa(d.port)), d.timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn = &timeoutConn{Conn: conn, timeout: d.timeout}
+	if d.ssl {
+		conn = tls.Client(conn, d.tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, d.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !d.ssl {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(d.tlsConfig); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if ok, mechanisms := client.Extension("AUTH"); ok && d.username != "" {
+		if err := client.Auth(d.auth(mechanisms)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return &smtpSender{client: client}, nil
+}
+
+// auth picks the mechanism like gomail: CRAM-MD5, LOGIN when PLAIN is not offered, and PLAIN otherwise.
+// PLAIN and LOGIN are used without TLS when the server offers them, as with gomail.Dialer.
+func (d *smtpDialer) auth(mechanisms string) smtp.Auth {
+	switch {
+	case strings.Contains(mechanisms, "CRAM-MD5"):
+		return smtp.CRAMMD5Auth(d.username, d.password)
+	case strings.Contains(mechanisms, "LOGIN") && !strings.Contains(mechanisms, "PLAIN"):
+		return &loginAuth{username: d.username, password: d.password, host: d.host}
+	default:
+		return &plainAuth{username: d.username, password: d.password, host: d.host}
+	}
+}
+
+// timeoutConn renews the connection's deadline before every read and write
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// smtpSender sends messages over one SMTP session
+type smtpSender struct {
+	client *smtp.Client
+}
+
+func (s *smtpSender) Send(from string, to []string, msg io.WriterTo) error {
+	if err := s.client.Mail(from); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := s.client.Rcpt(address); err != nil {
+			return err
+		}
+	}
+
+	w, err := s.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Close ends the session, dropping the connection when the server does not answer QUIT
+func (s *smtpSender) Close() error {
+	if err := s.client.Quit(); err != nil {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// checkAuthServer allows a mechanism over an unencrypted connection only when the server offers it
+func checkAuthServer(server *smtp.ServerInfo, host, mechanism string) error {
+	if !server.TLS && !slices.Contains(server.Auth, mechanism) {
+		return stdErrors.New("unencrypted connection")
+	}
+	if server.Name != host {
+		return stdErrors.New("wrong host name")
+	}
+	return nil
+}
+
+// plainAuth implements the PLAIN mechanism. Unlike smtp.PlainAuth it does not refuse unencrypted
+// connections to hosts other than localhost.
+type plainAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthServer(server, a.host, "PLAIN"); err != nil {
+		return "", nil, err
+	}
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, stdErrors.New("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthServer(server, a.host, "LOGIN"); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch {
+	case bytes.Equal(fromServer, []byte("Username:")):
+		return []byte(a.username), nil
+	case bytes.Equal(fromServer, []byte("Password:")):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
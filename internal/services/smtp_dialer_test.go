@@ -0,0 +1,73 @@
+package services
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"testing"
+	"time"
+)
+
+func TestSMTPDialerTimesOutOnHungServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Accept connections but never send the SMTP greeting
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	d := &smtpDialer{host: "127.0.0.1", port: addr.Port, tlsConfig: &tls.Config{}, timeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	if sender, err := d.Dial(); err == nil {
+		sender.Close()
+		t.Fatal("Dial succeeded without a greeting")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Dial took %s, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestSMTPAuthOverUnencryptedConnections(t *testing.T) {
+	d := &smtpDialer{host: "mail.example.com", username: "user", password: "secret"}
+
+	tests := []struct {
+		mechanisms string
+		server     smtp.ServerInfo
+		want       string
+		wantErr    bool
+	}{
+		// Relays without STARTTLS that offer PLAIN worked with gomail.Dialer
+		{mechanisms: "PLAIN LOGIN", server: smtp.ServerInfo{Name: "mail.example.com", Auth: []string{"PLAIN", "LOGIN"}}, want: "PLAIN"},
+		{mechanisms: "LOGIN", server: smtp.ServerInfo{Name: "mail.example.com", Auth: []string{"LOGIN"}}, want: "LOGIN"},
+		{mechanisms: "PLAIN", server: smtp.ServerInfo{Name: "mail.example.com", TLS: true}, want: "PLAIN"},
+		{mechanisms: "PLAIN", server: smtp.ServerInfo{Name: "mail.example.com"}, wantErr: true},
+		{mechanisms: "PLAIN", server: smtp.ServerInfo{Name: "other.example.com", TLS: true}, wantErr: true},
+	}
+	for _, test := range tests {
+		mechanism, response, err := d.auth(test.mechanisms).Start(&test.server)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q on %+v: expected an error", test.mechanisms, test.server)
+			}
+			continue
+		}
+		if err != nil || mechanism != test.want {
+			t.Errorf("%q on %+v = %s, %v, want %s", test.mechanisms, test.server, mechanism, err, test.want)
+		}
+		if mechanism == "PLAIN" && string(response) != "\x00user\x00secret" {
+			t.Errorf("PLAIN response = %q", response)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-utils/config"
+)
+
+// templateCacheKey identifies a compiled translation by the locale it was found for, not the requested one.
+// TemplateID and Version describe a database translation, so another replica publishing a version changes
+// the key even though this instance never saw the publish. Both are zero for file translations.
+type templateCacheKey struct {
+	Name       string
+	Locale     string
+	TemplateID uuid.UUID
+	Version    int
+}
+
+// templateRequestKey identifies a template as mails request it, by name and requested locale
+type templateRequestKey struct {
+	Name   string
+	Locale string
+}
+
+// TemplateCacheStats reports how often rendering found a compiled template in the cache
+type TemplateCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// templateCache holds compiled templates, including their layouts and partials, so mails are not parsed from scratch
+type templateCache struct {
+	mu      sync.RWMutex
+	entries map[templateCacheKey]*mailTemplate
+	// resolved maps requests to their compiled translation, so hits skip the database and file lookups.
+	// The watcher empties it every interval, so versions published by other replicas are picked up.
+	resolved map[templateRequestKey]*mailTemplate
+	// generation changes on every clear, so templates parsed from files that changed meanwhile are not stored
+	generation uint64
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
+
+var compiledTemplates = &templateCache{
+	entries:  make(map[templateCacheKey]*mailTemplate),
+	resolved: make(map[templateRequestKey]*mailTemplate),
+}
+
+// lookup returns the template a request resolved to earlier and the current generation to pass to put on a miss
+func (c *templateCache) lookup(request templateRequestKey) (*mailTemplate, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if tmpl, ok := c.resolved[request]; ok {
+		c.hits.Add(1)
+		return tmpl, c.generation
+	}
+	return nil, c.generation
+}
+
+// get returns the compiled translation
+func (c *templateCache) get(key templateCacheKey) *mailTemplate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if tmpl, ok := c.entries[key]; ok {
+		c.hits.Add(1)
+		return tmpl
+	}
+	c.misses.Add(1)
+	return nil
+}
+
+// put stores a translation resolved during the given generation for the request,
+// dropping it if the cache was cleared meanwhile
+func (c *templateCache) put(request templateRequestKey, key templateCacheKey, tmpl *mailTemplate, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation {
+		c.entries[key] = tmpl
+		c.resolved[request] = tmpl
+	}
+}
+
+// invalidate removes every cached locale and version of the named template
+func (c *templateCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.Name == name {
+			delete(c.entries, key)
+		}
+	}
+	for request := range c.resolved {
+		if request.Name == name {
+			delete(c.resolved, request)
+		}
+	}
+	c.generation++
+}
+
+// clear removes all cached templates
+func (c *templateCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[templateCacheKey]*mailTemplate)
+	c.resolved = make(map[templateRequestKey]*mailTemplate)
+	c.generation++
+}
+
+// forgetResolutions makes the next request of every template look its translation up again
+func (c *templateCache) forgetResolutions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved = make(map[templateRequestKey]*mailTemplate)
+}
+
+// GetTemplateCacheStats returns the hit and miss counts and the number of cached templates
+func GetTemplateCacheStats() TemplateCacheStats {
+	compiledTemplates.mu.RLock()
+	defer compiledTemplates.mu.RUnlock()
+	return TemplateCacheStats{
+		Hits:    compiledTemplates.hits.Load(),
+		Misses:  compiledTemplates.misses.Load(),
+		Entries: len(compiledTemplates.entries),
+	}
+}
+
+// newTemplateCacheKey builds the cache key for the translation found by locateTemplate
+func newTemplateCacheKey(source *templateSource) templateCacheKey {
+	key := templateCacheKey{Name: source.Name, Locale: source.Locale}
+	if source.Stored != nil {
+		key.TemplateID = source.Stored.ID
+		key.Version = source.Stored.PublishedVersion
+	}
+	return key
+}
+
+// StartTemplateWatcher polls the templates directory every TEMPLATE_CACHE_WATCH_INTERVAL seconds
+// and clears the template cache when a template, layout, partial or sidecar file changes.
+// Otherwise only the resolved requests are forgotten, so their database and file lookups run again.
+func StartTemplateWatcher() {
+	interval := time.Duration(config.GetEnvInt("TEMPLATE_CACHE_WATCH_INTERVAL", 2)) * time.Second
+
+	go func() {
+		last := templatesFingerprint()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			current := templatesFingerprint()
+			if current != last {
+				log.Println("Template files changed, clearing template cache")
+				compiledTemplates.clear()
+				last = current
+			} else {
+				compiledTemplates.forgetResolutions()
+			}
+		}
+	}()
+}
+
+// templatesFingerprint hashes the path, size and modification time of every file in the templates directory
+func templatesFingerprint() uint64 {
+	hash := fnv.New64a()
+	err := filepath.WalkDir(templatesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil && !stdErrors.Is(err, fs.ErrNotExist) {
+		log.Printf("Failed to scan templates directory: %v", err)
+	}
+	return hash.Sum64()
+}
@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestTemplateCacheResolutions(t *testing.T) {
+	cache := &templateCache{
+		entries:  make(map[templateCacheKey]*mailTemplate),
+		resolved: make(map[templateRequestKey]*mailTemplate),
+	}
+	request := templateRequestKey{Name: "welcome", Locale: "de-AT-x-a1"}
+	key := templateCacheKey{Name: "welcome", Locale: "de"}
+	tmpl := &mailTemplate{}
+
+	_, generation := cache.lookup(request)
+	cache.put(request, key, tmpl, generation)
+	if cached, _ := cache.lookup(request); cached != tmpl {
+		t.Fatal("resolved request not cached")
+	}
+
+	// Forgetting resolutions keeps the compiled translation
+	cache.forgetResolutions()
+	if cached, _ := cache.lookup(request); cached != nil {
+		t.Fatal("resolution survived forgetResolutions")
+	}
+	if cache.get(key) != tmpl {
+		t.Fatal("compiled translation dropped by forgetResolutions")
+	}
+
+	// Publishing drops both, and lookups started before are not stored
+	_, generation = cache.lookup(request)
+	cache.put(request, key, tmpl, generation)
+	cache.invalidate("welcome")
+	if cached, _ := cache.lookup(request); cached != nil || cache.get(key) != nil {
+		t.Fatal("invalidate kept the template")
+	}
+	cache.put(request, key, tmpl, generation)
+	if cached, _ := cache.lookup(request); cached != nil {
+		t.Fatal("stale resolution stored after invalidate")
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+	"time"
+)
+
+// dateLayouts holds the Go layouts for each date style, keyed by locale or language.
+// Month and weekday names are written in English and translated by dateNames.
+var dateLayouts = map[string]map[string]string{
+	"en":    {"short": "1/2/06", "medium": "Jan 2, 2006", "long": "January 2, 2006", "full": "Monday, January 2, 2006", "time": "3:04 PM"},
+	"en-GB": {"short": "02/01/2006", "medium": "2 Jan 2006", "long": "2 January 2006", "full": "Monday, 2 January 2006", "time": "15:04"},
+	"de":    {"short": "02.01.06", "medium": "02.01.2006", "long": "2. January 2006", "full": "Monday, 2. January 2006", "time": "15:04"},
+	"fr":    {"short": "02/01/2006", "medium": "2 Jan 2006", "long": "2 January 2006", "full": "Monday 2 January 2006", "time": "15:04"},
+	"es":    {"short": "2/1/06", "medium": "2 Jan 2006", "long": "2 de January de 2006", "full": "Monday, 2 de January de 2006", "time": "15:04"},
+	"it":    {"short": "02/01/06", "medium": "2 Jan 2006", "long": "2 January 2006", "full": "Monday 2 January 2006", "time": "15:04"},
+	"nl":    {"short": "02-01-2006", "medium": "2 Jan 2006", "long": "2 January 2006", "full": "Monday 2 January 2006", "time": "15:04"},
+	"pt":    {"short": "02/01/2006", "medium": "2 de Jan de 2006", "long": "2 de January de 2006", "full": "Monday, 2 de January de 2006", "time": "15:04"},
+}
+
+// dateNameSet holds the month and weekday names of a language, weekdays starting on Sunday like time.Weekday
+type dateNameSet struct {
+	months      [12]string
+	shortMonths [12]string
+	days        [7]string
+	shortDays   [7]string
+}
+
+// dateNames holds the names that replace the English ones of the January, Jan, Monday and Mon layout elements
+var dateNames = map[string]dateNameSet{
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:        [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+}
+
+// dateNameElements are the layout elements that print names, longer ones first so January wins over Jan
+var dateNameElements = []string{"January", "Jan", "Monday", "Mon"}
+
+// localizedLayout returns the layout for a style in the most specific matching locale, falling back to English.
+// Unknown styles are used as Go layouts.
+func localizedLayout(locale, style string) string {
+	for _, candidate := range localeChain(locale) {
+		if layouts, ok := dateLayouts[candidate]; ok {
+			if layout, ok := layouts[style]; ok {
+				return layout
+			}
+		}
+	}
+	if layout, ok := dateLayouts["en"][style]; ok {
+		return layout
+	}
+	return style
+}
+
+// formatLocalizedDate formats t in the given style with month and weekday names in the locale's language.
+// The name elements are substituted while formatting rather than translating the English output,
+// since the English full and abbreviated name of May are the same word.
+func formatLocalizedDate(t time.Time, style, locale string) string {
+	layout := localizedLayout(locale, style)
+
+	var names *dateNameSet
+	for _, candidate := range localeChain(locale) {
+		if set, ok := dateNames[candidate]; ok {
+			names = &set
+			break
+		}
+	}
+	if names == nil {
+		return t.Format(layout)
+	}
+
+	var formatted strings.Builder
+	for layout != "" {
+		index, element := -1, ""
+		for _, candidate := range dateNameElements {
+			if i := strings.Index(layout, candidate); i >= 0 && (index < 0 || i < index) {
+				index, element = i, candidate
+			}
+		}
+		if index < 0 {
+			formatted.WriteString(t.Format(layout))
+			break
+		}
+
+		formatted.WriteString(t.Format(layout[:index]))
+		switch element {
+		case "January":
+			formatted.WriteString(names.months[t.Month()-1])
+		case "Jan":
+			formatted.WriteString(names.shortMonths[t.Month()-1])
+		case "Monday":
+			formatted.WriteString(names.days[t.Weekday()])
+		case "Mon":
+			formatted.WriteString(names.shortDays[t.Weekday()])
+		}
+		layout = layout[index+len(element):]
+	}
+	return formatted.String()
+}
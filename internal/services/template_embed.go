@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mailer-api/internal/storage"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	texttemplate "text/template"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gopkg.in/gomail.v2"
+)
+
+// assetsDir holds the images templates embed with {{embed "logo.png"}}.
+// Assets not found there are read from attachment storage under assetsStoragePrefix.
+var assetsDir = filepath.Join(templatesDir, "assets")
+
+// assetsStoragePrefix confines embedded assets in attachment storage to their own prefix,
+// so templates cannot embed uploads or files attached by path
+const assetsStoragePrefix = "assets"
+
+// embedName restricts embedded asset names to characters that are valid in a Content-ID and a cid: URL
+var embedName = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// embedURL returns the cid: URL that references an asset embedded as an inline part of the mail
+func embedURL(name string) (template.URL, error) {
+	clean, err := embedAssetName(name)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("cid:" + clean), nil
+}
+
+// embedAssetName cleans an asset name and rejects names that are not relative paths of safe characters
+func embedAssetName(name string) (string, error) {
+	clean := path.Clean(name)
+	if !embedName.MatchString(clean) || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("embed %q: asset must be a relative path of letters, digits, '.', '_', '-' and '/'", name)
+	}
+	return clean, nil
+}
+
+// embedRecorder collects the assets one render embeds through {{embed}}, without duplicates.
+// Only the template function records assets, so cid: URLs in the mail data never add inline parts.
+type embedRecorder struct {
+	assets []string
+	seen   map[string]bool
+}
+
+func (r *embedRecorder) embed(name string) (template.URL, error) {
+	clean, err := embedAssetName(name)
+	if err != nil {
+		return "", err
+	}
+	if !r.seen[clean] {
+		r.seen[clean] = true
+		r.assets = append(r.assets, clean)
+	}
+	return template.URL("cid:" + clean), nil
+}
+
+func (r *embedRecorder) reset() {
+	r.assets = nil
+	r.seen = make(map[string]bool)
+}
+
+// templateRenderer holds clones of a template's parts bound to the locale and embed recorder of one render.
+// The cached template is never executed itself, since html/template cannot clone executed templates.
+type templateRenderer struct {
+	html *template.Template
+	// subject and text are nil when the template has no default subject or text body
+	subject  *texttemplate.Template
+	text     *texttemplate.Template
+	recorder *embedRecorder
+}
+
+// acquireRenderer returns a renderer formatting for the locale, reusing one released by an earlier render
+func (t *mailTemplate) acquireRenderer(locale string) (*templateRenderer, error) {
+	renderer, ok := t.renderers.Get().(*templateRenderer)
+	if !ok {
+		var err error
+		if renderer, err = t.newRenderer(); err != nil {
+			return nil, err
+		}
+	}
+	renderer.recorder.reset()
+
+	funcs := createTemplateFuncMap(locale)
+	if renderer.subject != nil {
+		renderer.subject.Funcs(texttemplate.FuncMap(funcs))
+	}
+	if renderer.text != nil {
+		renderer.text.Funcs(texttemplate.FuncMap(funcs))
+	}
+	renderer.html.Funcs(funcs)
+	renderer.html.Funcs(template.FuncMap{"embed": renderer.recorder.embed})
+	return renderer, nil
+}
+
+// newRenderer clones the parts of the template for a renderer
+func (t *mailTemplate) newRenderer() (*templateRenderer, error) {
+	html, err := t.HTML.Clone()
+	if err != nil {
+		return nil, err
+	}
+	renderer := &templateRenderer{html: html, recorder: &embedRecorder{}}
+	if t.SubjectTemplate != nil {
+		if renderer.subject, err = t.SubjectTemplate.Clone(); err != nil {
+			return nil, err
+		}
+	}
+	if t.Text != nil {
+		if renderer.text, err = t.Text.Clone(); err != nil {
+			return nil, err
+		}
+	}
+	return renderer, nil
+}
+
+// releaseRenderer makes a renderer available to the next render of the template
+func (t *mailTemplate) releaseRenderer(renderer *templateRenderer) {
+	t.renderers.Put(renderer)
+}
+
+// embedAssets adds the assets to the message as inline related parts whose Content-ID matches their cid: URL,
+// looking them up in templates/assets first and under assets/ in attachment storage second
+func embedAssets(m *gomail.Message, assets []string) error {
+	for _, asset := range assets {
+		contentID := gomail.SetHeader(map[string][]string{"Content-ID": {"<" + asset + ">"}})
+
+		local, err := localAssetExists(asset)
+		if err != nil {
+			return err
+		}
+		if local {
+			// gomail derives the Content-Type from the file extension
+			m.Embed(path.Base(asset), contentID, gomail.SetCopyFunc(copyFromAssets(asset)))
+			continue
+		}
+
+		key := path.Join(assetsStoragePrefix, asset)
+		exists, err := attachmentStorage.Exists(context.Background(), key)
+		if err != nil && !stdErrors.Is(err, storage.ErrInvalidKey) {
+			return err
+		}
+		if !exists {
+			return errors.NotFoundError("EMBED_NOT_FOUND", "Embedded asset not found").WithMetadata("asset", asset)
+		}
+
+		m.Embed(path.Base(asset), contentID, gomail.SetCopyFunc(copyFromStorage(key)))
+	}
+	return nil
+}
+
+// localAssetExists reports whether templates/assets holds the asset. Lookups go through an os.Root,
+// so neither "../" segments nor symlinks can reach files outside the directory.
+func localAssetExists(asset string) (bool, error) {
+	root, err := os.OpenRoot(assetsDir)
+	if stdErrors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer root.Close()
+
+	info, err := root.Stat(filepath.FromSlash(asset))
+	if err != nil {
+		// Missing files and paths escaping the directory are left to attachment storage
+		return false, nil
+	}
+	return !info.IsDir(), nil
+}
+
+// copyFromAssets returns a gomail copy func that streams an asset from templates/assets
+func copyFromAssets(asset string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		root, err := os.OpenRoot(assetsDir)
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+
+		content, err := root.Open(filepath.FromSlash(asset))
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		_, err = io.Copy(w, content)
+		return err
+	}
+}
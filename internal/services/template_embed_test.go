@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"mailer-api/internal/storage"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"gopkg.in/gomail.v2"
+)
+
+func newTestMailTemplate(t *testing.T, html string) *mailTemplate {
+	t.Helper()
+
+	tmpl, err := template.New("mail").Funcs(createTemplateFuncMap("en")).Parse(html)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return &mailTemplate{HTML: tmpl}
+}
+
+func TestRenderRecordsEmbeddedAssets(t *testing.T) {
+	tmpl := newTestMailTemplate(t, `<img src="{{embed "logo.png"}}"><img src="{{embed "./logo.png"}}">{{if .Banner}}<img src="{{embed "banners/spring.png"}}">{{end}}<p>{{.Note}}</p><a href="{{.Link}}">x</a>`)
+
+	// cid: URLs in the mail data are output, never embedded
+	data := map[string]interface{}{
+		"Banner": true,
+		"Note":   "cid:uploads/invoice.pdf",
+		"Link":   "cid:../../etc/passwd",
+	}
+	rendered, err := renderMailTemplate(tmpl, "en", "Hello", data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if want := []string{"logo.png", "banners/spring.png"}; !slices.Equal(rendered.Embeds, want) {
+		t.Fatalf("Embeds = %q, want %q", rendered.Embeds, want)
+	}
+	if !strings.Contains(rendered.HTML, `src="cid:logo.png"`) {
+		t.Fatalf("HTML does not reference the embedded logo:\n%s", rendered.HTML)
+	}
+
+	// Renders reuse recorders without carrying assets over
+	data["Banner"] = false
+	rendered, err = renderMailTemplate(tmpl, "en", "Hello", data)
+	if err != nil {
+		t.Fatalf("second render: %v", err)
+	}
+	if want := []string{"logo.png"}; !slices.Equal(rendered.Embeds, want) {
+		t.Fatalf("Embeds of second render = %q, want %q", rendered.Embeds, want)
+	}
+}
+
+func TestRenderRejectsUnsafeEmbedNames(t *testing.T) {
+	for _, name := range []string{"../secret.png", "/etc/passwd", "logo png", "a/../../b.png"} {
+		tmpl := newTestMailTemplate(t, `<img src="{{embed .Name}}">`)
+		if _, err := renderMailTemplate(tmpl, "en", "Hello", map[string]interface{}{"Name": name}); err == nil {
+			t.Errorf("embed %q rendered, want error", name)
+		}
+	}
+}
+
+func TestEmbedAssets(t *testing.T) {
+	dir := t.TempDir()
+	assets := filepath.Join(dir, "assets")
+	if err := os.MkdirAll(assets, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(assets, "logo.png"), []byte("local logo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.png"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.png"), filepath.Join(assets, "linked.png")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	backend, err := storage.NewLocalStorage(filepath.Join(dir, "attachments"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for key, content := range map[string]string{"assets/banner.png": "stored banner", "uploads/invoice.pdf": "invoice"} {
+		if err := backend.Put(ctx, key, strings.NewReader(content), -1, "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousDir, previousStorage := assetsDir, attachmentStorage
+	assetsDir, attachmentStorage = assets, backend
+	t.Cleanup(func() { assetsDir, attachmentStorage = previousDir, previousStorage })
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", "sender@example.com")
+	m.SetBody("text/html", `<img src="cid:logo.png"><img src="cid:banner.png">`)
+	if err := embedAssets(m, []string{"logo.png", "banner.png"}); err != nil {
+		t.Fatalf("embedAssets: %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := m.WriteTo(&out); err != nil {
+		t.Fatalf("write message: %v", err)
+	}
+	for _, want := range []string{"Content-ID: <logo.png>", "Content-ID: <banner.png>"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("message does not contain %q", want)
+		}
+	}
+
+	// Uploads are outside the assets prefix and symlinks cannot leave templates/assets
+	for _, asset := range []string{"uploads/invoice.pdf", "linked.png", "missing.png"} {
+		err := embedAssets(gomail.NewMessage(), []string{asset})
+		if !errors.IsCode(err, "EMBED_NOT_FOUND") {
+			t.Errorf("embedAssets(%q) error = %v, want EMBED_NOT_FOUND", asset, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kerimovok/go-pkg-utils/config"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// createTemplateFuncMap returns the functions available in subject, HTML and text templates.
+// Formatting functions use the mail's locale, an empty locale formats like English.
+//
+//	safeURL .link                            marks a URL as safe for href attributes
+//	embed "logo.png"                          embeds an asset from templates/assets or assets/ in attachment storage, e.g. <img src="{{embed "logo.png"}}">
+//	formatDate .date ["long"] ["Europe/Rome"] formats a date in a style (short, medium, long, full) or Go layout
+//	formatTime .date ["Europe/Rome"]          formats the time of day
+//	inTimezone "Europe/Rome" .date            converts a date to a timezone
+//	formatNumber .value [2]                   formats a number with grouping and at most n decimals
+//	formatCurrency .amount "EUR"              formats an amount with the currency symbol placed as in the locale
+//	default "fallback" .value                 returns the fallback when the value is empty
+//	upper, lower, title, trim                 change string case or trim whitespace
+//	plural .count "item" "items"              picks the singular for a count of 1, the plural otherwise
+//	join ", " .list                           joins a list into a string
+//	truncate 80 .text                         shortens text to n characters without splitting runes, adding "…"
+func createTemplateFuncMap(locale string) template.FuncMap {
+	tag := templateLanguage(locale)
+	printer := message.NewPrinter(tag)
+
+	return template.FuncMap{
+		"safeURL": func(s string) template.URL {
+			return template.URL(s)
+		},
+		"embed": embedURL,
+		"formatDate": func(value interface{}, args ...string) (string, error) {
+			t, err := toTime(value)
+			if err != nil {
+				return "", err
+			}
+			style, timezone := "medium", ""
+			if len(args) > 0 {
+				style = args[0]
+			}
+			if len(args) > 1 {
+				timezone = args[1]
+			}
+			if t, err = inTimezone(timezone, t); err != nil {
+				return "", err
+			}
+			return formatLocalizedDate(t, style, locale), nil
+		},
+		"formatTime": func(value interface{}, args ...string) (string, error) {
+			t, err := toTime(value)
+			if err != nil {
+				return "", err
+			}
+			timezone := ""
+			if len(args) > 0 {
+				timezone = args[0]
+			}
+			if t, err = inTimezone(timezone, t); err != nil {
+				return "", err
+			}
+			return t.Format(localizedLayout(locale, "time")), nil
+		},
+		"inTimezone": func(timezone string, value interface{}) (time.Time, error) {
+			t, err := toTime(value)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return inTimezone(timezone, t)
+		},
+		"formatNumber": func(value interface{}, decimals ...int) (string, error) {
+			f, err := toFloat(value)
+			if err != nil {
+				return "", err
+			}
+			maxDecimals := 2
+			if len(decimals) > 0 {
+				maxDecimals = decimals[0]
+			}
+			return printer.Sprint(number.Decimal(f, number.MaxFractionDigits(maxDecimals))), nil
+		},
+		"formatCurrency": func(value interface{}, code string) (string, error) {
+			f, err := toFloat(value)
+			if err != nil {
+				return "", err
+			}
+			unit, err := currency.ParseISO(code)
+			if err != nil {
+				return "", fmt.Errorf("unknown currency %q", code)
+			}
+			return formatLocalizedCurrency(printer, locale, unit, f), nil
+		},
+		"default": func(fallback, value interface{}) interface{} {
+			if isEmptyValue(value) {
+				return fallback
+			}
+			return value
+		},
+		"upper": func(s string) string {
+			return cases.Upper(tag).String(s)
+		},
+		"lower": func(s string) string {
+			return cases.Lower(tag).String(s)
+		},
+		"title": func(s string) string {
+			return cases.Title(tag).String(s)
+		},
+		"trim": strings.TrimSpace,
+		"plural": func(count interface{}, singular, plural string) (string, error) {
+			n, err := toFloat(count)
+			if err != nil {
+				return "", err
+			}
+			if n == 1 {
+				return singular, nil
+			}
+			return plural, nil
+		},
+		"join": func(separator string, list interface{}) (string, error) {
+			value := reflect.ValueOf(list)
+			if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+				return "", fmt.Errorf("join expects a list, got %T", list)
+			}
+			parts := make([]string, value.Len())
+			for i := range parts {
+				parts[i] = fmt.Sprint(value.Index(i).Interface())
+			}
+			return strings.Join(parts, separator), nil
+		},
+		"truncate": func(length int, s string) string {
+			if utf8.RuneCountInString(s) <= length {
+				return s
+			}
+			runes := []rune(s)
+			if length <= 1 {
+				return string(runes[:max(length, 0)])
+			}
+			return strings.TrimRightFunc(string(runes[:length-1]), func(r rune) bool { return r == ' ' }) + "…"
+		},
+	}
+}
+
+// templateLanguage parses the mail locale, falling back to English
+func templateLanguage(locale string) language.Tag {
+	if tag, err := language.Parse(locale); err == nil && locale != "" {
+		return tag
+	}
+	return language.English
+}
+
+// currencyPatterns places the currency symbol (¤) around the amount (#), keyed by locale or language.
+// Languages without an entry use the English pattern.
+var currencyPatterns = map[string]string{
+	"en":    "¤#",
+	"de":    "#\u00a0¤",
+	"fr":    "#\u00a0¤",
+	"es":    "#\u00a0¤",
+	"it":    "#\u00a0¤",
+	"nl":    "¤\u00a0#",
+	"pt":    "#\u00a0¤",
+	"pt-BR": "¤\u00a0#",
+}
+
+// formatLocalizedCurrency formats an amount with the locale's digits and separators and places the symbol
+// per currencyPatterns. x/text only renders the symbol in front, separated by a space.
+func formatLocalizedCurrency(printer *message.Printer, locale string, unit currency.Unit, amount float64) string {
+	symbol := printer.Sprint(currency.Symbol(unit))
+	formatted := strings.TrimPrefix(printer.Sprint(currency.Symbol(unit.Amount(amount))), symbol)
+	formatted = strings.TrimSpace(formatted)
+	sign := ""
+	if strings.HasPrefix(formatted, "-") {
+		sign, formatted = "-", strings.TrimPrefix(formatted, "-")
+	}
+
+	pattern := currencyPatterns["en"]
+	for _, candidate := range localeChain(locale) {
+		if p, ok := currencyPatterns[candidate]; ok {
+			pattern = p
+			break
+		}
+	}
+	// Letter symbols such as CHF are always set apart from the amount
+	if pattern == "¤#" && strings.IndexFunc(symbol, unicode.IsLetter) >= 0 {
+		pattern = "¤\u00a0#"
+	}
+
+	return sign + strings.NewReplacer("¤", symbol, "#", formatted).Replace(pattern)
+}
+
+// inTimezone converts t to the named timezone, defaulting to TEMPLATE_TIMEZONE
+func inTimezone(timezone string, t time.Time) (time.Time, error) {
+	if timezone == "" {
+		timezone = config.GetEnvOrDefault("TEMPLATE_TIMEZONE", "UTC")
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	return t.In(location), nil
+}
+
+// toTime accepts time values, RFC 3339 or YYYY-MM-DD strings and Unix timestamps in seconds
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.DateOnly, v); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a date", v)
+	default:
+		if seconds, err := toFloat(v); err == nil {
+			return time.Unix(int64(seconds), 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot use %T as a date", value)
+}
+
+// toFloat accepts numbers of any type, json.Number and numeric strings
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("cannot use %T as a number", value)
+}
+
+// isEmptyValue reports whether a value is missing, zero or an empty string, list or map
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// renderFunc executes a template snippet with the function map of a locale
+func renderFunc(t *testing.T, locale, snippet string, data any) string {
+	t.Helper()
+
+	tmpl, err := template.New("test").Funcs(template.FuncMap(createTemplateFuncMap(locale))).Parse(snippet)
+	if err != nil {
+		t.Fatalf("parse %q: %v", snippet, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatalf("execute %q: %v", snippet, err)
+	}
+	return out.String()
+}
+
+func TestFormatLocalizedDate(t *testing.T) {
+	may := time.Date(2006, time.May, 2, 15, 4, 5, 0, time.UTC)
+	march := time.Date(2024, time.March, 3, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale string
+		style  string
+		date   time.Time
+		want   string
+	}{
+		{locale: "", style: "medium", date: may, want: "May 2, 2006"},
+		{locale: "en", style: "full", date: may, want: "Tuesday, May 2, 2006"},
+		{locale: "en-GB", style: "long", date: may, want: "2 May 2006"},
+		{locale: "en-US", style: "short", date: may, want: "5/2/06"},
+		{locale: "de", style: "long", date: march, want: "3. März 2024"},
+		{locale: "de-AT", style: "full", date: may, want: "Dienstag, 2. Mai 2006"},
+		{locale: "fr", style: "medium", date: march, want: "3 mars 2024"},
+		{locale: "fr", style: "full", date: may, want: "mardi 2 mai 2006"},
+		{locale: "nl", style: "medium", date: march, want: "3 mrt 2024"},
+		{locale: "nl", style: "long", date: may, want: "2 mei 2006"},
+
+		// The English full and abbreviated name of May are the same word
+		{locale: "es", style: "medium", date: may, want: "2 may 2006"},
+		{locale: "es", style: "long", date: may, want: "2 de mayo de 2006"},
+		{locale: "it", style: "medium", date: may, want: "2 mag 2006"},
+		{locale: "it", style: "long", date: may, want: "2 maggio 2006"},
+		{locale: "pt", style: "medium", date: may, want: "2 de mai de 2006"},
+		{locale: "pt-BR", style: "full", date: may, want: "terça-feira, 2 de maio de 2006"},
+
+		{locale: "ja", style: "medium", date: may, want: "May 2, 2006"},
+		{locale: "de", style: "Mon 02.01.", date: march, want: "So 03.03."},
+		{locale: "it", style: "time", date: may, want: "15:04"},
+		{locale: "en", style: "time", date: may, want: "3:04 PM"},
+	}
+
+	for _, tt := range tests {
+		if got := formatLocalizedDate(tt.date, tt.style, tt.locale); got != tt.want {
+			t.Errorf("formatLocalizedDate(%s, %q, %q) = %q, want %q", tt.date.Format(time.DateOnly), tt.style, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDateFunc(t *testing.T) {
+	t.Setenv("TEMPLATE_TIMEZONE", "UTC")
+
+	tests := []struct {
+		locale  string
+		snippet string
+		want    string
+	}{
+		{locale: "en", snippet: `{{formatDate .}}`, want: "May 2, 2006"},
+		{locale: "es", snippet: `{{formatDate . "long"}}`, want: "2 de mayo de 2006"},
+		{locale: "de", snippet: `{{formatDate . "long" "Asia/Tokyo"}}`, want: "3. Mai 2006"},
+		{locale: "en-GB", snippet: `{{formatTime . "Europe/London"}}`, want: "00:04"},
+		{locale: "en", snippet: `{{formatDate "2006-05-02"}}`, want: "May 2, 2006"},
+		{locale: "en", snippet: `{{formatDate 1146582245}}`, want: "May 2, 2006"},
+	}
+
+	date := time.Date(2006, time.May, 2, 23, 4, 5, 0, time.UTC)
+	for _, tt := range tests {
+		if got := renderFunc(t, tt.locale, tt.snippet, date); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.locale, tt.snippet, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCurrencyFunc(t *testing.T) {
+	tests := []struct {
+		locale string
+		amount any
+		code   string
+		want   string
+	}{
+		{locale: "", amount: 1234.5, code: "USD", want: "$1,234.50"},
+		{locale: "en", amount: 1234.5, code: "EUR", want: "€1,234.50"},
+		{locale: "en", amount: -1234.5, code: "EUR", want: "-€1,234.50"},
+		{locale: "en", amount: 1234.5, code: "CHF", want: "CHF\u00a01,234.50"},
+		{locale: "en", amount: 1234.5, code: "JPY", want: "¥1,235"},
+		{locale: "de", amount: 1234.56, code: "EUR", want: "1.234,56\u00a0€"},
+		{locale: "de-AT", amount: -5, code: "EUR", want: "-5,00\u00a0€"},
+		{locale: "fr", amount: 1234.56, code: "EUR", want: "1\u00a0234,56\u00a0€"},
+		{locale: "es", amount: json.Number("99.9"), code: "EUR", want: "99,90\u00a0€"},
+		{locale: "it", amount: "12", code: "EUR", want: "12,00\u00a0€"},
+		{locale: "nl", amount: 1234.56, code: "EUR", want: "€\u00a01.234,56"},
+		{locale: "pt", amount: 1234.56, code: "EUR", want: "1.234,56\u00a0€"},
+		{locale: "pt-BR", amount: 1234.56, code: "BRL", want: "R$\u00a01.234,56"},
+	}
+
+	for _, tt := range tests {
+		got := renderFunc(t, tt.locale, `{{formatCurrency .Amount .Code}}`, map[string]any{"Amount": tt.amount, "Code": tt.code})
+		if got != tt.want {
+			t.Errorf("formatCurrency %v %s in %q = %q, want %q", tt.amount, tt.code, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCurrencyRejectsUnknownCurrency(t *testing.T) {
+	tmpl := template.Must(template.New("test").Funcs(template.FuncMap(createTemplateFuncMap("en"))).Parse(`{{formatCurrency 1 "XYZ1"}}`))
+	err := tmpl.Execute(&bytes.Buffer{}, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown currency "XYZ1"`) {
+		t.Fatalf("error = %v, want unknown currency", err)
+	}
+}
+
+func TestFormatNumberFunc(t *testing.T) {
+	tests := []struct {
+		locale  string
+		snippet string
+		want    string
+	}{
+		{locale: "en", snippet: `{{formatNumber 1234567.891}}`, want: "1,234,567.89"},
+		{locale: "de", snippet: `{{formatNumber 1234567.891}}`, want: "1.234.567,89"},
+		{locale: "en", snippet: `{{formatNumber 3.14159 4}}`, want: "3.1416"},
+		{locale: "en", snippet: `{{formatNumber 42 0}}`, want: "42"},
+		{locale: "fr", snippet: `{{formatNumber "1234.5"}}`, want: "1\u00a0234,5"},
+	}
+
+	for _, tt := range tests {
+		if got := renderFunc(t, tt.locale, tt.snippet, nil); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.locale, tt.snippet, got, tt.want)
+		}
+	}
+}
+
+func TestTextFuncs(t *testing.T) {
+	data := map[string]any{
+		"Empty": "",
+		"Name":  "Ada",
+		"Tags":  []string{"a", "b", "c"},
+		"Count": 1,
+		"Many":  3,
+	}
+
+	tests := []struct {
+		locale  string
+		snippet string
+		want    string
+	}{
+		{locale: "en", snippet: `{{default "friend" .Empty}}`, want: "friend"},
+		{locale: "en", snippet: `{{default "friend" .Name}}`, want: "Ada"},
+		{locale: "en", snippet: `{{default "friend" .Missing}}`, want: "friend"},
+		{locale: "en", snippet: `{{plural .Count "item" "items"}}`, want: "item"},
+		{locale: "en", snippet: `{{plural .Many "item" "items"}}`, want: "items"},
+		{locale: "en", snippet: `{{join ", " .Tags}}`, want: "a, b, c"},
+		{locale: "en", snippet: `{{upper "straße"}}`, want: "STRASSE"},
+		{locale: "tr", snippet: `{{upper "i"}}`, want: "İ"},
+		{locale: "en", snippet: `{{title "hello world"}}`, want: "Hello World"},
+		{locale: "en", snippet: `{{trim "  hi  "}}`, want: "hi"},
+		{locale: "en", snippet: `{{truncate 7 "Grüße aus Köln"}}`, want: "Grüße…"},
+		{locale: "en", snippet: `{{truncate 20 "short"}}`, want: "short"},
+	}
+
+	for _, tt := range tests {
+		if got := renderFunc(t, tt.locale, tt.snippet, data); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.locale, tt.snippet, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+// HTML templates share the partials in templates/partials and may extend a layout in templates/layouts.
+// Each partial is available under its file name without extension, e.g. {{template "footer" .}}.
+// A template extends a layout by starting with {{/* extends "base" */}}; the layout then renders
+// the template's {{define "..."}} blocks, typically through {{block "content" .}}{{end}}.
+var (
+	partialsDir = filepath.Join(templatesDir, "partials")
+	layoutsDir  = filepath.Join(templatesDir, "layouts")
+)
+
+// extendsDirective matches the layout declaration at the top of a template
+var extendsDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*extends\s+"([A-Za-z0-9_-]+)"\s*\*/\s*-?\}\}`)
+
+// parseHTMLTemplate parses an HTML template body together with the shared partials and the layout it extends.
+// The returned template executes the layout when there is one, otherwise the body itself.
+func parseHTMLTemplate(name, body string, strict bool) (*template.Template, error) {
+	root := template.New(name + ".html").
+		Funcs(createTemplateFuncMap("")).
+		Option(missingKeyOption(strict))
+
+	if match := extendsDirective.FindStringSubmatch(body); match != nil {
+		layout, err := os.ReadFile(filepath.Join(layoutsDir, match[1]+".html"))
+		if os.IsNotExist(err) {
+			return nil, errors.NotFoundError("LAYOUT_NOT_FOUND", "Layout not found").
+				WithMetadata("template", name).
+				WithMetadata("layout", match[1])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The layout becomes the entry point and the body only contributes its define blocks
+		if _, err := root.Parse(string(layout)); err != nil {
+			return nil, templateError("PARSE_TEMPLATE", "Failed to parse layout", "layout", err)
+		}
+		if _, err := root.New(name + ".body").Parse(body); err != nil {
+			return nil, templateError("PARSE_TEMPLATE", "Failed to parse template", "html", err)
+		}
+	} else if _, err := root.Parse(body); err != nil {
+		return nil, templateError("PARSE_TEMPLATE", "Failed to parse template", "html", err)
+	}
+
+	partials, err := filepath.Glob(filepath.Join(partialsDir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	for _, partial := range partials {
+		content, err := os.ReadFile(partial)
+		if err != nil {
+			return nil, err
+		}
+		partialName := strings.TrimSuffix(filepath.Base(partial), ".html")
+		// Templates may define a block of the same name themselves, which takes precedence
+		if root.Lookup(partialName) != nil {
+			continue
+		}
+		if _, err := root.New(partialName).Parse(string(content)); err != nil {
+			return nil, templateError("PARSE_TEMPLATE", "Failed to parse partial", "partial", err)
+		}
+	}
+
+	return root, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestExtendsDirective(t *testing.T) {
+	tests := []struct {
+		body   string
+		layout string
+	}{
+		{body: `{{/* extends "base" */}}{{define "content"}}x{{end}}`, layout: "base"},
+		{body: "\n  {{- /* extends \"mail-v2\" */ -}}\n", layout: "mail-v2"},
+		{body: `{{/*extends "base_2"*/}}`, layout: "base_2"},
+		// Only a declaration at the top of the template counts
+		{body: `<p></p>{{/* extends "base" */}}`},
+		{body: `{{/* extends "../secret" */}}`},
+		{body: `{{/* extends base */}}`},
+		{body: `{{/* layout "base" */}}`},
+	}
+	for _, test := range tests {
+		var layout string
+		if match := extendsDirective.FindStringSubmatch(test.body); match != nil {
+			layout = match[1]
+		}
+		if layout != test.layout {
+			t.Errorf("extendsDirective on %q = %q, want %q", test.body, layout, test.layout)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/kerimovok/go-pkg-utils/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// templateMetadata holds the defaults a template declares for the mails that use it; the fields of a mail
+// request override them. Database templates keep them in their fields, file templates in an optional
+// templates/<name>[.<locale>].yaml sidecar found through the locale chain, e.g.
+//
+//	subject: "Your order {{.order.id}}"
+//	from: "Shop <orders@example.com>"
+//	category: transactional
+//	requiredVars: [order.id, customer.name]
+//	strictMode: true
+//	inlineCss: true
+type templateMetadata struct {
+	Subject      string   `yaml:"subject"`
+	From         string   `yaml:"from"`
+	Category     string   `yaml:"category"`
+	RequiredVars []string `yaml:"requiredVars"`
+	StrictMode   *bool    `yaml:"strictMode"`
+	InlineCSS    *bool    `yaml:"inlineCss"`
+}
+
+// loadTemplateMetadata returns the metadata of the template found by locateTemplate.
+// File templates without a sidecar have empty metadata.
+func loadTemplateMetadata(source *templateSource) (*templateMetadata, error) {
+	if stored := source.Stored; stored != nil {
+		return &templateMetadata{
+			Subject:      stored.Subject,
+			From:         stored.From,
+			Category:     stored.Category,
+			RequiredVars: stored.RequiredVars,
+			StrictMode:   stored.StrictMode,
+			InlineCSS:    stored.InlineCSS,
+		}, nil
+	}
+
+	var metadata templateMetadata
+	sidecarPath := findLocalizedFile(source.Name, ".yaml", localeChain(source.Locale))
+	if sidecarPath == "" {
+		return &metadata, nil
+	}
+	content, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return nil, invalidSidecarError("INVALID_TEMPLATE_METADATA", "Template metadata is invalid", "metadata", source.Name, err)
+	}
+	if metadata.From != "" {
+		if _, err := mail.ParseAddress(metadata.From); err != nil {
+			return nil, invalidSidecarError("INVALID_TEMPLATE_METADATA", "Template metadata is invalid", "metadata", source.Name,
+				fmt.Errorf("invalid from address: %w", err))
+		}
+	}
+	return &metadata, nil
+}
+
+// invalidSidecarError reports a malformed metadata or schema sidecar with a code, like a template that fails to parse,
+// so validation leaves the mail to delivery and the render preview reports the failing part
+func invalidSidecarError(code, message, part, templateName string, err error) error {
+	return errors.InternalError(code, message).
+		WithMetadata("template", templateName).
+		WithMetadata("part", part).
+		WithMetadata("error", err.Error())
+}
+
+// missingRequiredVars reports every required variable that data does not provide.
+// Nested keys are separated by dots, e.g. order.id, and reported like missing schema properties.
+func missingRequiredVars(required []string, data map[string]interface{}) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	for _, name := range required {
+		var value interface{} = data
+		for _, key := range strings.Split(name, ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[key]
+		}
+		if value == nil {
+			errs = append(errs, validator.FieldError{Field: "data." + name, Message: "field is required", Tag: "required"})
+		}
+	}
+	return errs
+}
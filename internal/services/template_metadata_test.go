@@ -0,0 +1,62 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+func TestMalformedSidecarsAreCoded(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir(templatesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sidecars := map[string]string{
+		"badyaml.yaml":          "subject: [unclosed",
+		"badfrom.yaml":          "from: not an address",
+		"badjson.schema.json":   "{",
+		"badschema.schema.json": `{"type": "no-such-type"}`,
+	}
+	for name, content := range sidecars {
+		if err := os.WriteFile(filepath.Join(templatesDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"badyaml", "badfrom"} {
+		_, err := loadTemplateMetadata(&templateSource{Name: name})
+		if !errors.IsCode(err, "INVALID_TEMPLATE_METADATA") {
+			t.Errorf("loadTemplateMetadata(%s) error = %v, want INVALID_TEMPLATE_METADATA", name, err)
+		}
+	}
+	for _, name := range []string{"badjson", "badschema"} {
+		_, err := loadTemplateSchema(&templateSource{Name: name})
+		if !errors.IsCode(err, "INVALID_TEMPLATE_SCHEMA") {
+			t.Errorf("loadTemplateSchema(%s) error = %v, want INVALID_TEMPLATE_SCHEMA", name, err)
+		}
+	}
+}
+
+func TestMissingRequiredVars(t *testing.T) {
+	data := map[string]interface{}{
+		"name":  "Ada",
+		"empty": "",
+		"nil":   nil,
+		"order": map[string]interface{}{"id": 1, "customer": map[string]interface{}{"email": "a@example.com"}},
+		"list":  []interface{}{1},
+	}
+	required := []string{"name", "empty", "nil", "missing", "order.id", "order.total", "order.customer.email", "name.first", "list.0"}
+
+	var fields []string
+	for _, fieldErr := range missingRequiredVars(required, data) {
+		fields = append(fields, fieldErr.Field)
+	}
+	// Present but empty values count as provided, nesting only walks objects
+	want := []string{"data.nil", "data.missing", "data.order.total", "data.name.first", "data.list.0"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("missing = %q, want %q", fields, want)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+// RenderedMail is the subject and bodies produced by rendering a template with a data map.
+// Text is derived from the HTML when the template has no text version.
+type RenderedMail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+	// Version is the database template version that was rendered, 0 for file templates
+	Version   int        `json:"version,omitempty"`
+	VersionID *uuid.UUID `json:"versionId,omitempty"`
+	// Locale is the translation that was rendered, empty for the default template
+	Locale string `json:"locale,omitempty"`
+	// Embeds lists the assets the HTML references through {{embed}}, sent as inline parts
+	Embeds []string `json:"embeds,omitempty"`
+	// From and Category are the template's defaults, which the fields of a mail override
+	From     string `json:"from,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// RenderTemplate renders the most specific translation of the named template for the locale with data
+// and the brand variables of the tenant and the template's sender. Nothing is stored and no mail is sent.
+func RenderTemplate(name, locale, subject, tenant string, data map[string]interface{}) (*RenderedMail, error) {
+	tmpl, err := resolveTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := mailSender("", tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return renderMailTemplate(tmpl, locale, subject, withBrand(data, tenant, sender.Address))
+}
+
+// renderMailTemplate renders a resolved template for the requested locale with data.
+// An empty subject falls back to the template's default subject, or to a {{define "subject"}} block in the
+// template, so translated templates carry translated subjects.
+func renderMailTemplate(tmpl *mailTemplate, locale, subject string, data map[string]interface{}) (*RenderedMail, error) {
+	renderer, err := tmpl.acquireRenderer(locale)
+	if err != nil {
+		return nil, templateError("EXECUTE_TEMPLATE", "Failed to execute template", "html", err)
+	}
+	defer tmpl.releaseRenderer(renderer)
+
+	var parsedSubject bytes.Buffer
+	switch {
+	case subject != "":
+		// The subject is a header, not HTML, so it is rendered as plain text with the template functions
+		subjectTmpl, err := parseSubjectTemplate(subject, locale, tmpl.Strict)
+		if err != nil {
+			return nil, err
+		}
+		if err := subjectTmpl.Execute(&parsedSubject, data); err != nil {
+			return nil, templateError("EXECUTE_SUBJECT", "Failed to execute subject template", "subject", err)
+		}
+	case renderer.subject != nil:
+		// The template's default subject was parsed together with the template
+		if err := renderer.subject.Execute(&parsedSubject, data); err != nil {
+			return nil, templateError("EXECUTE_SUBJECT", "Failed to execute subject template", "subject", err)
+		}
+	case renderer.html.Lookup("subject") != nil:
+		if err := renderer.html.ExecuteTemplate(&parsedSubject, "subject", data); err != nil {
+			return nil, templateError("EXECUTE_SUBJECT", "Failed to execute subject template", "subject", err)
+		}
+		// The block is rendered in HTML context, the subject header needs the plain text
+		plain := strings.TrimSpace(html.UnescapeString(parsedSubject.String()))
+		parsedSubject.Reset()
+		parsedSubject.WriteString(plain)
+	}
+
+	var body bytes.Buffer
+	if err := renderer.html.Execute(&body, data); err != nil {
+		return nil, templateError("EXECUTE_TEMPLATE", "Failed to execute template", "html", err)
+	}
+
+	rendered := &RenderedMail{
+		Subject:   parsedSubject.String(),
+		HTML:      body.String(),
+		Version:   tmpl.Version,
+		VersionID: tmpl.VersionID,
+		Locale:    tmpl.Locale,
+		From:      tmpl.From,
+		Category:  tmpl.Category,
+	}
+
+	if renderer.text != nil {
+		var text bytes.Buffer
+		if err := renderer.text.Execute(&text, data); err != nil {
+			return nil, templateError("EXECUTE_TEMPLATE", "Failed to execute template", "text", err)
+		}
+		rendered.Text = text.String()
+	} else {
+		rendered.Text = htmlToText(rendered.HTML)
+	}
+
+	if tmpl.InlineCSS {
+		inlined, err := inlineCSS(rendered.HTML)
+		if err != nil {
+			return nil, templateError("INLINE_CSS", "Failed to inline template CSS", "html", err)
+		}
+		rendered.HTML = inlined
+	}
+	rendered.Embeds = slices.Clone(renderer.recorder.assets)
+
+	return rendered, nil
+}
+
+// parseSubjectTemplate parses a subject as a plain-text template with the functions of the locale
+func parseSubjectTemplate(subject, locale string, strict bool) (*texttemplate.Template, error) {
+	subjectTmpl, err := texttemplate.New("subject").
+		Funcs(texttemplate.FuncMap(createTemplateFuncMap(locale))).
+		Option(missingKeyOption(strict)).
+		Parse(subject)
+	if err != nil {
+		return nil, templateError("PARSE_SUBJECT", "Failed to parse subject template", "subject", err)
+	}
+	return subjectTmpl, nil
+}
+
+// templateErrorPosition matches the "template: name:line:" and "template: name:line:column:" prefixes
+// of text/template and html/template errors
+var templateErrorPosition = regexp.MustCompile(`^(?:html/)?template: ?[^:]*:(\d+)(?::(\d+))?:`)
+
+// missingKeyPattern matches the execution error raised for absent keys under missingkey=error
+var missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// missingKeyOption returns the template option for strict or lenient handling of missing keys
+func missingKeyOption(strict bool) string {
+	if strict {
+		return "missingkey=error"
+	}
+	return "missingkey=default"
+}
+
+// templateError wraps a parse or execution error, adding the failing part and its line and column when known.
+// Missing keys in strict mode are reported as MISSING_TEMPLATE_KEY, which no retry can fix.
+func templateError(code, message, part string, err error) error {
+	missingKey := missingKeyPattern.FindStringSubmatch(err.Error())
+	if missingKey != nil {
+		code, message = "MISSING_TEMPLATE_KEY", "Template data is missing a key used by the template"
+	}
+
+	templateErr := errors.InternalError(code, message).
+		WithMetadata("error", err.Error()).
+		WithMetadata("part", part)
+	if missingKey != nil {
+		templateErr.WithMetadata("key", missingKey[1])
+	}
+
+	if match := templateErrorPosition.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		templateErr.WithMetadata("line", line)
+		if match[2] != "" {
+			column, _ := strconv.Atoi(match[2])
+			templateErr.WithMetadata("column", column)
+		}
+	}
+
+	return templateErr
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	stdErrors "errors"
+	"html/template"
+	"io"
+	"testing"
+	texttemplate "text/template"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+func TestRenderBindsFunctionsToRequestedLocale(t *testing.T) {
+	tmpl := newTestMailTemplate(t, `{{formatNumber .Amount}}`)
+	text, err := texttemplate.New("text").Funcs(texttemplate.FuncMap(createTemplateFuncMap(""))).Parse(`{{formatNumber .Amount}}`)
+	if err != nil {
+		t.Fatalf("parse text: %v", err)
+	}
+	tmpl.Text = text
+	data := map[string]interface{}{"Amount": 1234.5}
+
+	// One compiled template serves every locale, renders must not leak their locale into the next
+	for _, test := range []struct{ locale, want string }{
+		{locale: "de", want: "1.234,5"},
+		{locale: "en", want: "1,234.5"},
+		{locale: "de-x-a1", want: "1.234,5"},
+	} {
+		rendered, err := renderMailTemplate(tmpl, test.locale, "Hello", data)
+		if err != nil {
+			t.Fatalf("render %s: %v", test.locale, err)
+		}
+		if rendered.HTML != test.want || rendered.Text != test.want {
+			t.Errorf("render %s = %q / %q, want %q", test.locale, rendered.HTML, rendered.Text, test.want)
+		}
+	}
+}
+
+func TestTemplateErrorPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func() error
+		wantCode   string
+		wantLine   int
+		wantColumn int
+		wantKey    string
+	}{
+		{
+			name: "text parse error",
+			parse: func() error {
+				_, err := texttemplate.New("t").Parse("Hello\n{{.Name")
+				return err
+			},
+			wantCode: "PARSE_TEMPLATE",
+			wantLine: 2,
+		},
+		{
+			name: "html parse error",
+			parse: func() error {
+				_, err := template.New("t").Parse("<p>\n\n{{if .X}}</p>")
+				return err
+			},
+			wantCode: "PARSE_TEMPLATE",
+			wantLine: 3,
+		},
+		{
+			name: "execution error with column",
+			parse: func() error {
+				tmpl := texttemplate.Must(texttemplate.New("t").Parse("a\nb {{index .List 5}}"))
+				return tmpl.Execute(io.Discard, map[string]interface{}{"List": []int{1}})
+			},
+			wantCode:   "PARSE_TEMPLATE",
+			wantLine:   2,
+			wantColumn: 4,
+		},
+		{
+			name: "strict missing key",
+			parse: func() error {
+				tmpl := template.Must(template.New("t").Option(missingKeyOption(true)).Parse("<p>{{.Name}}</p>"))
+				return tmpl.Execute(io.Discard, map[string]interface{}{})
+			},
+			wantCode:   "MISSING_TEMPLATE_KEY",
+			wantLine:   1,
+			wantColumn: 5,
+			wantKey:    "Name",
+		},
+		{
+			name:     "error without position",
+			parse:    func() error { return stdErrors.New("boom") },
+			wantCode: "PARSE_TEMPLATE",
+		},
+	}
+
+	for _, test := range tests {
+		err := test.parse()
+		if err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+		var templateErr *errors.Error
+		if !stdErrors.As(templateError("PARSE_TEMPLATE", "Failed to parse template", "html", err), &templateErr) {
+			t.Fatalf("%s: templateError did not return an *errors.Error", test.name)
+		}
+		if templateErr.Code != test.wantCode {
+			t.Errorf("%s: code = %s, want %s", test.name, templateErr.Code, test.wantCode)
+		}
+		if line, _ := templateErr.Metadata["line"].(int); line != test.wantLine {
+			t.Errorf("%s: line = %d, want %d (%v)", test.name, line, test.wantLine, err)
+		}
+		if column, _ := templateErr.Metadata["column"].(int); column != test.wantColumn {
+			t.Errorf("%s: column = %d, want %d (%v)", test.name, column, test.wantColumn, err)
+		}
+		if key, _ := templateErr.Metadata["key"].(string); key != test.wantKey {
+			t.Errorf("%s: key = %q, want %q", test.name, key, test.wantKey)
+		}
+	}
+}
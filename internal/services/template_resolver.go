@@ -0,0 +1,253 @@
+package services
+
+import (
+	"html/template"
+	"log"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/google/uuid"
+	"github.com/kerimovok/go-pkg-utils/config"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// templatesDir holds the file templates used when no database template has the requested name
+const templatesDir = "templates"
+
+// mailTemplate is a parsed template ready to render a mail body
+type mailTemplate struct {
+	// Subject is the template's default subject, used when the mail has none, and SubjectTemplate its parsed form
+	Subject         string
+	SubjectTemplate *texttemplate.Template
+	HTML            *template.Template
+	// Text renders the plain-text body, nil when it is derived from the HTML instead
+	Text *texttemplate.Template
+	// Version is the published database template version, 0 for file templates
+	Version int
+	// VersionID is the ID of the published version row, nil for file templates
+	VersionID *uuid.UUID
+	// Strict makes missing data keys fail rendering instead of printing "<no value>"
+	Strict bool
+	// Locale is the translation that was found, empty for the default template
+	Locale string
+	// InlineCSS moves the rules of <style> elements into style attributes after rendering
+	InlineCSS bool
+	// From and Category are the template's defaults for mails that do not set them
+	From     string
+	Category string
+	// RequiredVars and Schema describe the data mails must provide, Schema is nil when the template has none
+	RequiredVars []string
+	Schema       *jsonschema.Schema
+
+	// renderers pools the clones that renders execute, see acquireRenderer
+	renderers sync.Pool
+}
+
+// hasSubject reports whether the template provides a subject for mails that do not set one,
+// either as its default subject or as a {{define "subject"}} block
+func (t *mailTemplate) hasSubject() bool {
+	return t.SubjectTemplate != nil || t.HTML.Lookup("subject") != nil
+}
+
+// templateStrictMode applies a template's strict mode override to the TEMPLATE_STRICT_MODE default
+func templateStrictMode(override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return config.GetEnvBool("TEMPLATE_STRICT_MODE", false)
+}
+
+// templateInlineCSS applies a template's CSS inlining override to the TEMPLATE_INLINE_CSS default
+func templateInlineCSS(override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return config.GetEnvBool("TEMPLATE_INLINE_CSS", false)
+}
+
+// localeChain lists the locales to try for a requested locale, most specific first,
+// e.g. "de-AT" gives "de-AT", "de" and "" for the default template
+func localeChain(locale string) []string {
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(chain, "")
+}
+
+// localizedFileName returns the file name of a template translation, e.g. welcome.de-AT.html
+func localizedFileName(name, locale, ext string) string {
+	if locale == "" {
+		return name + ext
+	}
+	return name + "." + locale + ext
+}
+
+// findLocalizedFile returns the path of the most specific existing translation of name<ext>, or "" if none exists
+func findLocalizedFile(name, ext string, chain []string) string {
+	for _, locale := range chain {
+		path := filepath.Join(templatesDir, localizedFileName(name, locale, ext))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// templateSource is where the most specific translation of a template was found:
+// a published database template, or a file in the templates directory
+type templateSource struct {
+	Name   string
+	Stored *models.Template
+	Path   string
+	Locale string
+}
+
+// findStoredTemplate returns the database template for the most specific locale of the chain, or nil if there is none
+func findStoredTemplate(name, locale string) (*models.Template, error) {
+	chain := localeChain(locale)
+
+	var stored []models.Template
+	if err := database.DB.Where("name = ? AND locale IN ?", name, chain).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, candidate := range chain {
+		for i := range stored {
+			if stored[i].Locale == candidate {
+				return &stored[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// locateTemplate walks the locale chain from the most specific locale to the default template.
+// For each locale the database template found by findStoredTemplate takes precedence over the file.
+func locateTemplate(name, locale string, stored *models.Template) (*templateSource, error) {
+	for _, candidate := range localeChain(locale) {
+		if stored != nil && stored.Locale == candidate {
+			return &templateSource{Name: name, Stored: stored, Locale: candidate}, nil
+		}
+		path := filepath.Join(templatesDir, localizedFileName(name, candidate, ".html"))
+		if _, err := os.Stat(path); err == nil {
+			return &templateSource{Name: name, Path: path, Locale: candidate}, nil
+		}
+	}
+
+	log.Printf("Template file not found: %s", filepath.Join(templatesDir, name+".html"))
+	return nil, errors.NotFoundError("TEMPLATE_NOT_FOUND", "Template file not found").
+		WithMetadata("template", name).
+		WithMetadata("locale", locale)
+}
+
+// resolveTemplate loads the most specific translation of the template for the locale, preferring the published
+// version of a database template over templates/<name>[.<locale>].html at each step of the locale chain.
+// Compiled templates are cached per translation until a newer version is published or the template files change;
+// rendering binds the formatting functions to the requested locale.
+func resolveTemplate(name, locale string) (*mailTemplate, error) {
+	request := templateRequestKey{Name: name, Locale: locale}
+	cached, generation := compiledTemplates.lookup(request)
+	if cached != nil {
+		return cached, nil
+	}
+
+	stored, err := findStoredTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+	source, err := locateTemplate(name, locale, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	key := newTemplateCacheKey(source)
+	resolved := compiledTemplates.get(key)
+	if resolved == nil {
+		if resolved, err = parseTemplate(source); err != nil {
+			return nil, err
+		}
+	}
+	compiledTemplates.put(request, key, resolved, generation)
+	return resolved, nil
+}
+
+// parseTemplate compiles the template selected by locateTemplate together with its metadata.
+// Formatting functions are bound to the mail's locale when rendering, see acquireRenderer.
+func parseTemplate(source *templateSource) (*mailTemplate, error) {
+	name, stored := source.Name, source.Stored
+	metadata, err := loadTemplateMetadata(source)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &mailTemplate{
+		Subject:      metadata.Subject,
+		Strict:       templateStrictMode(metadata.StrictMode),
+		Locale:       source.Locale,
+		InlineCSS:    templateInlineCSS(metadata.InlineCSS),
+		From:         metadata.From,
+		Category:     metadata.Category,
+		RequiredVars: metadata.RequiredVars,
+	}
+
+	if resolved.Subject != "" {
+		if resolved.SubjectTemplate, err = parseSubjectTemplate(resolved.Subject, "", resolved.Strict); err != nil {
+			return nil, err
+		}
+	}
+	if resolved.Schema, err = loadTemplateSchema(source); err != nil {
+		return nil, err
+	}
+
+	if source.Stored != nil {
+		resolved.Version = stored.PublishedVersion
+		resolved.VersionID = stored.PublishedVersionID
+		if resolved.HTML, err = parseHTMLTemplate(name, stored.HTMLBody, resolved.Strict); err != nil {
+			return nil, err
+		}
+
+		if stored.TextBody != "" {
+			resolved.Text, err = texttemplate.New(name + ".txt").
+				Funcs(texttemplate.FuncMap(createTemplateFuncMap(""))).
+				Option(missingKeyOption(resolved.Strict)).
+				Parse(stored.TextBody)
+			if err != nil {
+				return nil, templateError("PARSE_TEMPLATE", "Failed to parse template", "text", err)
+			}
+		}
+		return resolved, nil
+	}
+
+	body, err := os.ReadFile(source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create template with function map, partials and layout
+	if resolved.HTML, err = parseHTMLTemplate(name, string(body), resolved.Strict); err != nil {
+		return nil, err
+	}
+
+	// An optional templates/<name>[.<locale>].txt provides the plain-text body
+	if textPath := findLocalizedFile(name, ".txt", localeChain(source.Locale)); textPath != "" {
+		resolved.Text, err = texttemplate.New(filepath.Base(textPath)).
+			Funcs(texttemplate.FuncMap(createTemplateFuncMap(""))).
+			Option(missingKeyOption(resolved.Strict)).
+			ParseFiles(textPath)
+		if err != nil {
+			return nil, templateError("PARSE_TEMPLATE", "Failed to parse template", "text", err)
+		}
+	}
+
+	return resolved, nil
+}
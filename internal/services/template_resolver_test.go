@@ -0,0 +1,72 @@
+package services
+
+import (
+	"mailer-api/internal/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+)
+
+func TestLocaleChain(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{locale: "", want: []string{""}},
+		{locale: "de", want: []string{"de", ""}},
+		{locale: "de-AT", want: []string{"de-AT", "de", ""}},
+		{locale: "zh-Hant-TW", want: []string{"zh-Hant-TW", "zh-Hant", "zh", ""}},
+	}
+	for _, test := range tests {
+		if got := localeChain(test.locale); !slices.Equal(got, test.want) {
+			t.Errorf("localeChain(%q) = %q, want %q", test.locale, got, test.want)
+		}
+	}
+}
+
+func TestLocateTemplate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir(templatesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"welcome.html", "welcome.de.html", "welcome.de-AT.html", "receipt.fr.html"} {
+		if err := os.WriteFile(filepath.Join(templatesDir, name), []byte("<p></p>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		locale     string
+		stored     *models.Template
+		wantLocale string
+		wantStored bool
+	}{
+		{name: "welcome", locale: "", wantLocale: ""},
+		{name: "welcome", locale: "de-AT", wantLocale: "de-AT"},
+		{name: "welcome", locale: "de-CH", wantLocale: "de"},
+		{name: "welcome", locale: "fr", wantLocale: ""},
+		// A database translation wins over a file of the same locale, but not over a more specific file
+		{name: "welcome", locale: "de-AT", stored: &models.Template{Name: "welcome", Locale: "de"}, wantLocale: "de-AT"},
+		{name: "welcome", locale: "de-CH", stored: &models.Template{Name: "welcome", Locale: "de"}, wantLocale: "de", wantStored: true},
+		{name: "receipt", locale: "fr-CA", wantLocale: "fr"},
+	}
+	for _, test := range tests {
+		source, err := locateTemplate(test.name, test.locale, test.stored)
+		if err != nil {
+			t.Fatalf("locateTemplate(%s, %q): %v", test.name, test.locale, err)
+		}
+		if source.Locale != test.wantLocale || (source.Stored != nil) != test.wantStored {
+			t.Errorf("locateTemplate(%s, %q) = locale %q, stored %v, want locale %q, stored %v",
+				test.name, test.locale, source.Locale, source.Stored != nil, test.wantLocale, test.wantStored)
+		}
+	}
+
+	// receipt has no default translation to fall back to
+	if _, err := locateTemplate("receipt", "de", nil); !errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+		t.Errorf("locateTemplate(receipt, de) error = %v, want TEMPLATE_NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/kerimovok/go-pkg-utils/validator"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ValidateTemplateSchema checks that a schema submitted with a template compiles
+func ValidateTemplateSchema(schema map[string]interface{}) validator.ValidationErrors {
+	if schema == nil {
+		return nil
+	}
+	if _, err := compileSchema("schema.json", schema); err != nil {
+		return validator.ValidationErrors{{Field: "schema", Message: err.Error()}}
+	}
+	return nil
+}
+
+// TemplateCheck is the template of a mail, resolved once to validate the mail's subject and data against
+type TemplateCheck struct {
+	tmpl *mailTemplate
+}
+
+// ResolveTemplateCheck resolves the translation of the named template selected by locale for validation.
+// An unknown template is reported as a template field error, a template that fails to load, e.g. because
+// its schema or metadata is malformed, is returned as error.
+func ResolveTemplateCheck(templateName, locale string) (*TemplateCheck, validator.ValidationErrors, error) {
+	tmpl, err := resolveTemplate(templateName, locale)
+	if errors.IsCode(err, "TEMPLATE_NOT_FOUND") {
+		return nil, validator.ValidationErrors{{Field: "template", Message: "template not found", Value: templateName}}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &TemplateCheck{tmpl: tmpl}, nil, nil
+}
+
+// ValidateData validates data against the required variables and the JSON Schema of the template,
+// taken from the published database template or from the templates/<name>[.<locale>].yaml and .schema.json sidecars.
+// Templates without either accept any data. Field names of the returned errors are prefixed with "data".
+func (c *TemplateCheck) ValidateData(data map[string]interface{}) (validator.ValidationErrors, error) {
+	errs := missingRequiredVars(c.tmpl.RequiredVars, data)
+	schema := c.tmpl.Schema
+	if schema == nil {
+		return errs, nil
+	}
+
+	// Round-trip the data through JSON so the schema sees plain JSON values
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var instance interface{}
+	if err := json.Unmarshal(encoded, &instance); err != nil {
+		return nil, err
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if err := schema.Validate(instance); err != nil {
+		if !stdErrors.As(err, &validationErr) {
+			return nil, err
+		}
+		return append(errs, schemaFieldErrors(validationErr)...), nil
+	}
+	return errs, nil
+}
+
+// ValidateSubject checks that a mail without a subject uses a template that provides one
+func (c *TemplateCheck) ValidateSubject(subject string) validator.ValidationErrors {
+	if subject != "" || c.tmpl.hasSubject() {
+		return nil
+	}
+	return validator.ValidationErrors{{Field: "subject", Message: "subject is required, the template has no default subject", Tag: "required"}}
+}
+
+// loadTemplateSchema returns the compiled schema of the template found by locateTemplate, or nil if it has none
+func loadTemplateSchema(source *templateSource) (*jsonschema.Schema, error) {
+	templateName := source.Name
+	if source.Stored != nil {
+		if source.Stored.Schema == nil {
+			return nil, nil
+		}
+		schema, err := compileSchema(templateName+".schema.json", source.Stored.Schema)
+		if err != nil {
+			return nil, invalidSidecarError("INVALID_TEMPLATE_SCHEMA", "Template schema is invalid", "schema", templateName, err)
+		}
+		return schema, nil
+	}
+
+	schemaPath := findLocalizedFile(templateName, ".schema.json", localeChain(source.Locale))
+	if schemaPath == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return nil, invalidSidecarError("INVALID_TEMPLATE_SCHEMA", "Template schema is invalid", "schema", templateName, err)
+	}
+	compiled, err := compileSchema(filepath.Base(schemaPath), schema)
+	if err != nil {
+		return nil, invalidSidecarError("INVALID_TEMPLATE_SCHEMA", "Template schema is invalid", "schema", templateName, err)
+	}
+	return compiled, nil
+}
+
+func compileSchema(url string, schema map[string]interface{}) (*jsonschema.Schema, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.CompileString(url, string(encoded))
+}
+
+// missingPropertyPattern extracts the property names from "missing properties: 'a', 'b'"
+var missingPropertyPattern = regexp.MustCompile(`'([^']*)'`)
+
+// schemaFieldErrors flattens a schema validation error into one field error per failing value
+func schemaFieldErrors(err *jsonschema.ValidationError) validator.ValidationErrors {
+	if len(err.Causes) > 0 {
+		var errs validator.ValidationErrors
+		for _, cause := range err.Causes {
+			errs = append(errs, schemaFieldErrors(cause)...)
+		}
+		return errs
+	}
+
+	field := schemaFieldName(err.InstanceLocation)
+
+	// Report each missing property on its own field, like a required struct tag would
+	if strings.HasSuffix(err.KeywordLocation, "/required") {
+		var errs validator.ValidationErrors
+		for _, match := range missingPropertyPattern.FindAllStringSubmatch(err.Message, -1) {
+			errs = append(errs, validator.FieldError{Field: field + "." + match[1], Message: "field is required", Tag: "required"})
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+
+	return validator.ValidationErrors{{Field: field, Message: err.Message}}
+}
+
+// schemaFieldName turns a JSON pointer such as /items/0/name into data.items[0].name
+func schemaFieldName(pointer string) string {
+	field := "data"
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		if _, err := strconv.Atoi(segment); err == nil {
+			field += "[" + segment + "]"
+		} else {
+			field += "." + segment
+		}
+	}
+	return field
+}
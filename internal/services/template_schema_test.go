@@ -0,0 +1,115 @@
+package services
+
+import (
+	stdErrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestSchemaFieldErrors(t *testing.T) {
+	schema, err := compileSchema("test.schema.json", map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name", "order"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"order": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"id", "total"},
+				"properties": map[string]interface{}{
+					"items": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "object", "required": []interface{}{"sku"}},
+					},
+				},
+			},
+			"a/b": map[string]interface{}{"type": "integer"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance map[string]interface{}
+		want     []string
+	}{
+		{name: "missing properties", instance: map[string]interface{}{}, want: []string{"data.name", "data.order"}},
+		{
+			name:     "nested missing properties",
+			instance: map[string]interface{}{"name": "x", "order": map[string]interface{}{"id": 1}},
+			want:     []string{"data.order.total"},
+		},
+		{
+			name: "array index",
+			instance: map[string]interface{}{"name": "x", "order": map[string]interface{}{
+				"id": 1, "total": 2, "items": []interface{}{map[string]interface{}{"sku": "a"}, map[string]interface{}{}},
+			}},
+			want: []string{"data.order.items[1].sku"},
+		},
+		{name: "wrong type", instance: map[string]interface{}{"name": 5, "order": map[string]interface{}{"id": 1, "total": 2}}, want: []string{"data.name"}},
+		{name: "escaped pointer", instance: map[string]interface{}{"name": "x", "order": map[string]interface{}{"id": 1, "total": 2}, "a/b": "x"}, want: []string{"data.a/b"}},
+	}
+	for _, test := range tests {
+		var validationErr *jsonschema.ValidationError
+		if !stdErrors.As(schema.Validate(test.instance), &validationErr) {
+			t.Fatalf("%s: expected a validation error", test.name)
+		}
+		var fields []string
+		for _, fieldErr := range schemaFieldErrors(validationErr) {
+			fields = append(fields, fieldErr.Field)
+		}
+		slices.Sort(fields)
+		if !slices.Equal(fields, test.want) {
+			t.Errorf("%s: fields = %q, want %q", test.name, fields, test.want)
+		}
+	}
+}
+
+func TestSchemaFieldName(t *testing.T) {
+	tests := map[string]string{
+		"":              "data",
+		"/name":         "data.name",
+		"/items/0/name": "data.items[0].name",
+		"/a~1b/c~0d":    "data.a/b.c~d",
+	}
+	for pointer, want := range tests {
+		if got := schemaFieldName(pointer); got != want {
+			t.Errorf("schemaFieldName(%q) = %q, want %q", pointer, got, want)
+		}
+	}
+}
+
+func TestTemplateCheck(t *testing.T) {
+	schema, err := compileSchema("test.schema.json", map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	check := &TemplateCheck{tmpl: newTestMailTemplate(t, "<p>{{.name}}</p>")}
+	check.tmpl.RequiredVars = []string{"name"}
+	check.tmpl.Schema = schema
+
+	if errs := check.ValidateSubject(""); len(errs) != 1 || errs[0].Field != "subject" {
+		t.Errorf("ValidateSubject without subject = %v, want a subject error", errs)
+	}
+	if errs := check.ValidateSubject("Hello"); errs.HasErrors() {
+		t.Errorf("ValidateSubject with subject = %v", errs)
+	}
+
+	errs, err := check.ValidateData(map[string]interface{}{"count": "three"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, fieldErr := range errs {
+		fields = append(fields, fieldErr.Field)
+	}
+	if want := []string{"data.name", "data.count"}; !slices.Equal(fields, want) {
+		t.Errorf("ValidateData fields = %q, want %q", fields, want)
+	}
+}
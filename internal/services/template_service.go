@@ -0,0 +1,269 @@
+package services
+
+import (
+	stdErrors "errors"
+	"html/template"
+	"mailer-api/internal/database"
+	"mailer-api/internal/models"
+	"mailer-api/internal/requests"
+	texttemplate "text/template"
+
+	"github.com/kerimovok/go-pkg-database/sql"
+	"github.com/kerimovok/go-pkg-utils/errors"
+	"github.com/kerimovok/go-pkg-utils/validator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ValidateTemplateSyntax parses the subject, HTML body and text body and reports the ones that do not parse
+func ValidateTemplateSyntax(subject, htmlBody, textBody string) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	if _, err := texttemplate.New("subject").Funcs(texttemplate.FuncMap(createTemplateFuncMap(""))).Parse(subject); err != nil {
+		errs = append(errs, validator.FieldError{Field: "subject", Message: err.Error()})
+	}
+	if _, err := template.New("html").Funcs(createTemplateFuncMap("")).Parse(htmlBody); err != nil {
+		errs = append(errs, validator.FieldError{Field: "htmlBody", Message: err.Error()})
+	}
+	if _, err := texttemplate.New("text").Funcs(texttemplate.FuncMap(createTemplateFuncMap(""))).Parse(textBody); err != nil {
+		errs = append(errs, validator.FieldError{Field: "textBody", Message: err.Error()})
+	}
+	return errs
+}
+
+// CreateTemplate stores a new template together with its first version, which is published right away.
+// Returns TEMPLATE_EXISTS if the name is taken.
+func CreateTemplate(input requests.CreateTemplateRequest) (*models.Template, error) {
+	tmpl := models.Template{
+		Name:             input.Name,
+		Locale:           input.Locale,
+		Subject:          input.Subject,
+		HTMLBody:         input.HTMLBody,
+		TextBody:         input.TextBody,
+		Schema:           sql.JSONB(input.Schema),
+		StrictMode:       input.StrictMode,
+		InlineCSS:        input.InlineCSS,
+		From:             input.From,
+		Category:         input.Category,
+		RequiredVars:     models.StringList(input.RequiredVars),
+		PublishedVersion: 1,
+		LatestVersion:    1,
+	}
+
+	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(&tmpl).Error; err != nil {
+			return err
+		}
+		version := models.TemplateVersion{
+			TemplateID:   tmpl.ID,
+			Version:      1,
+			Subject:      tmpl.Subject,
+			HTMLBody:     tmpl.HTMLBody,
+			TextBody:     tmpl.TextBody,
+			Schema:       tmpl.Schema,
+			StrictMode:   tmpl.StrictMode,
+			InlineCSS:    tmpl.InlineCSS,
+			From:         tmpl.From,
+			Category:     tmpl.Category,
+			RequiredVars: tmpl.RequiredVars,
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		tmpl.PublishedVersionID = &version.ID
+		return tx.Model(&tmpl).Update("published_version_id", version.ID).Error
+	})
+	if err != nil {
+		if stdErrors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, errors.ConflictError("TEMPLATE_EXISTS", "A template with this name and locale already exists").
+				WithMetadata("name", input.Name).
+				WithMetadata("locale", input.Locale)
+		}
+		return nil, err
+	}
+
+	// Mails of this name no longer render the file template or a less specific translation
+	compiledTemplates.invalidate(tmpl.Name)
+	return &tmpl, nil
+}
+
+// ListTemplates returns all templates stored in the database ordered by name and locale
+func ListTemplates() ([]models.Template, error) {
+	var templates []models.Template
+	if err := database.DB.Order("name, locale").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetTemplate returns the database template with the given name and locale, an empty locale being the default translation
+func GetTemplate(name, locale string) (*models.Template, error) {
+	var tmpl models.Template
+	err := database.DB.Where("name = ? AND locale = ?", name, locale).First(&tmpl).Error
+	if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NotFoundError("TEMPLATE_NOT_FOUND", "Template not found").
+			WithMetadata("template", name).
+			WithMetadata("locale", locale)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// CreateTemplateVersion stores the input as the next version of the template without publishing it
+func CreateTemplateVersion(name, locale string, input requests.UpdateTemplateRequest) (*models.TemplateVersion, error) {
+	// Look the template up first, errors.IsCode does not see domain errors wrapped by the transaction
+	if _, err := GetTemplate(name, locale); err != nil {
+		return nil, err
+	}
+
+	var version models.TemplateVersion
+	err := sql.WithTransaction(database.DB, func(tx *gorm.DB) error {
+		// Lock the template so concurrent edits get consecutive version numbers
+		var tmpl models.Template
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ? AND locale = ?", name, locale).First(&tmpl).Error; err != nil {
+			return err
+		}
+
+		version = models.TemplateVersion{
+			TemplateID:   tmpl.ID,
+			Version:      tmpl.LatestVersion + 1,
+			Subject:      input.Subject,
+			HTMLBody:     input.HTMLBody,
+			TextBody:     input.TextBody,
+			Schema:       sql.JSONB(input.Schema),
+			StrictMode:   input.StrictMode,
+			InlineCSS:    input.InlineCSS,
+			From:         input.From,
+			Category:     input.Category,
+			RequiredVars: models.StringList(input.RequiredVars),
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&tmpl).Update("latest_version", version.Version).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// ListTemplateVersions returns every version of a template, newest first
+func ListTemplateVersions(name, locale string) ([]models.TemplateVersion, error) {
+	tmpl, err := GetTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []models.TemplateVersion
+	if err := database.DB.Where("template_id = ?", tmpl.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetTemplateVersion returns a single version of a template
+func GetTemplateVersion(name, locale string, version int) (*models.TemplateVersion, error) {
+	tmpl, err := GetTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+	return findTemplateVersion(tmpl, version)
+}
+
+// PublishTemplateVersion makes the given version, or the latest one when version is 0, the live content of the template
+func PublishTemplateVersion(name, locale string, version int) (*models.Template, error) {
+	tmpl, err := GetTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		version = tmpl.LatestVersion
+	}
+
+	return publishTemplateVersion(tmpl, version)
+}
+
+// RollbackTemplate publishes an earlier version of the template. When version is 0 the highest
+// version below the published one is used, returning NO_PREVIOUS_TEMPLATE_VERSION if there is none.
+func RollbackTemplate(name, locale string, version int) (*models.Template, error) {
+	tmpl, err := GetTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		var previous models.TemplateVersion
+		err := database.DB.Where("template_id = ? AND version < ?", tmpl.ID, tmpl.PublishedVersion).Order("version DESC").First(&previous).Error
+		if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ConflictError("NO_PREVIOUS_TEMPLATE_VERSION", "Template has no version before the published one").
+				WithMetadata("publishedVersion", tmpl.PublishedVersion)
+		}
+		if err != nil {
+			return nil, err
+		}
+		version = previous.Version
+	}
+
+	return publishTemplateVersion(tmpl, version)
+}
+
+// publishTemplateVersion copies the content of a version onto the template
+func publishTemplateVersion(tmpl *models.Template, version int) (*models.Template, error) {
+	published, err := findTemplateVersion(tmpl, version)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.Subject = published.Subject
+	tmpl.HTMLBody = published.HTMLBody
+	tmpl.TextBody = published.TextBody
+	tmpl.Schema = published.Schema
+	tmpl.StrictMode = published.StrictMode
+	tmpl.InlineCSS = published.InlineCSS
+	tmpl.From = published.From
+	tmpl.Category = published.Category
+	tmpl.RequiredVars = published.RequiredVars
+	tmpl.PublishedVersion = published.Version
+	tmpl.PublishedVersionID = &published.ID
+
+	if err := database.DB.Model(tmpl).Select("subject", "html_body", "text_body", "schema", "strict_mode", "inline_css", "from_address", "category", "required_vars", "published_version", "published_version_id").Updates(tmpl).Error; err != nil {
+		return nil, err
+	}
+	compiledTemplates.invalidate(tmpl.Name)
+	return tmpl, nil
+}
+
+func findTemplateVersion(tmpl *models.Template, version int) (*models.TemplateVersion, error) {
+	var found models.TemplateVersion
+	err := database.DB.Where("template_id = ? AND version = ?", tmpl.ID, version).First(&found).Error
+	if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NotFoundError("TEMPLATE_VERSION_NOT_FOUND", "Template version not found").
+			WithMetadata("template", tmpl.Name).
+			WithMetadata("version", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// DeleteTemplate removes a database template. A file template of the same name and locale is used again afterwards.
+// Its versions are kept, since sent mails reference the version they were rendered with.
+func DeleteTemplate(name, locale string) error {
+	tmpl, err := GetTemplate(name, locale)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Delete(tmpl).Error; err != nil {
+		return err
+	}
+
+	compiledTemplates.invalidate(name)
+	return nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLocalStorage(t *testing.T) (*LocalStorage, string) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "attachments")
+	s, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	t.Cleanup(func() { s.root.Close() })
+	return s, dir
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	s, dir := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "uploads/report.txt", strings.NewReader("quarterly report"), -1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "uploads", "report.txt")); err != nil {
+		t.Fatalf("content not written below the storage directory: %v", err)
+	}
+
+	exists, err := s.Exists(ctx, "uploads/report.txt")
+	if err != nil || !exists {
+		t.Fatalf("Exists = %v, %v, want true", exists, err)
+	}
+
+	content, err := s.Open(ctx, "uploads/report.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	body, err := io.ReadAll(content)
+	content.Close()
+	if err != nil || string(body) != "quarterly report" {
+		t.Fatalf("Open content = %q, %v", body, err)
+	}
+
+	if err := s.Delete(ctx, "uploads/report.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := s.Exists(ctx, "uploads/report.txt"); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v, want false", exists, err)
+	}
+	if _, err := s.Open(ctx, "uploads/report.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "uploads/report.txt"); err != nil {
+		t.Fatalf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestLocalStorageDirectoryIsNotAFile(t *testing.T) {
+	s, _ := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "uploads/report.txt", strings.NewReader("report"), -1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if exists, err := s.Exists(ctx, "uploads"); err != nil || exists {
+		t.Fatalf("Exists(directory) = %v, %v, want false", exists, err)
+	}
+}
+
+func TestLocalStorageRejectsTraversal(t *testing.T) {
+	s, dir := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	outside := filepath.Join(filepath.Dir(dir), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../secret.txt", "uploads/../../secret.txt", "/etc/passwd", `..\secret.txt`, outside} {
+		if err := s.Put(ctx, key, strings.NewReader("overwritten"), -1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.Exists(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Exists(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if err := s.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if content, err := os.ReadFile(outside); err != nil || string(content) != "secret" {
+		t.Fatalf("file outside the storage directory changed: %q, %v", content, err)
+	}
+}
+
+func TestLocalStorageRejectsSymlinkEscape(t *testing.T) {
+	s, dir := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "secret.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"linked/secret.txt", "secret.txt"} {
+		if content, err := s.Open(ctx, key); err == nil {
+			content.Close()
+			t.Errorf("Open(%q) followed a symlink out of the storage directory", key)
+		}
+	}
+	if err := s.Put(ctx, "linked/new.txt", strings.NewReader("planted"), -1, "text/plain"); err == nil {
+		t.Errorf("Put wrote through a symlink out of the storage directory")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Errorf("file planted outside the storage directory")
+	}
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory, path-style S3 endpoint implementing the calls S3Storage makes:
+// bucket lookup and creation, single and multipart uploads, stat, download and delete
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: make(map[string]bool),
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+
+	if !f.buckets[bucket] {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	name := bucket + "/" + key
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: id})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		body, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var object []byte
+		for _, number := range numbers {
+			object = append(object, parts[number]...)
+		}
+		f.objects[name] = object
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"object"`})
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[name] = body
+		w.Header().Set("ETag", `"object"`)
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		object, ok := f.objects[name]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"object"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		if r.Method == http.MethodGet {
+			w.Write(object)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) object(name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[name]
+	return object, ok
+}
+
+// readS3Body reads a request body, decoding the aws-chunked encoding minio uses for streaming signatures
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			// Trailing checksum headers, if any, follow the last chunk
+			return body.Bytes(), nil
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
+
+func newTestS3Storage(t *testing.T) (*S3Storage, *fakeS3) {
+	t.Helper()
+
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "attachments",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s, fake
+}
+
+func TestNewS3StorageCreatesBucket(t *testing.T) {
+	_, fake := newTestS3Storage(t)
+	if !fake.buckets["attachments"] {
+		t.Fatal("bucket was not created")
+	}
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	content := "quarterly report"
+
+	tests := []struct {
+		name string
+		size int64
+	}{
+		{name: "known size", size: int64(len(content))},
+		{name: "unknown size", size: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestS3Storage(t)
+
+			if err := s.Put(ctx, "uploads/report.txt", strings.NewReader(content), tt.size, "text/plain"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if object, ok := fake.object("attachments/uploads/report.txt"); !ok || string(object) != content {
+				t.Fatalf("stored object = %q, %v", object, ok)
+			}
+
+			exists, err := s.Exists(ctx, "uploads/report.txt")
+			if err != nil || !exists {
+				t.Fatalf("Exists = %v, %v, want true", exists, err)
+			}
+
+			reader, err := s.Open(ctx, "uploads/report.txt")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			body, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil || string(body) != content {
+				t.Fatalf("Open content = %q, %v", body, err)
+			}
+
+			if err := s.Delete(ctx, "uploads/report.txt"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if exists, err := s.Exists(ctx, "uploads/report.txt"); err != nil || exists {
+				t.Fatalf("Exists after Delete = %v, %v, want false", exists, err)
+			}
+			if _, err := s.Open(ctx, "uploads/report.txt"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Open after Delete error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StorageRejectsInvalidKeys(t *testing.T) {
+	s, fake := newTestS3Storage(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../secret.txt", "/etc/passwd", "uploads/../../secret.txt"} {
+		if err := s.Put(ctx, key, strings.NewReader("content"), -1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.Exists(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Exists(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if err := s.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if len(fake.objects) != 0 {
+		t.Fatalf("objects stored for invalid keys: %v", fake.objects)
+	}
+}
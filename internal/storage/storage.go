@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerimovok/go-pkg-utils/config"
+)
+
+var (
+	// ErrNotFound is returned when no object exists under the key
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is returned for keys that are absolute or would escape the storage root
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage holds attachment content addressed by slash-separated keys
+type Storage interface {
+	// Put stores the content read from r under key. size may be -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns a reader for the content under key, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists reports whether content is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the content under key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// New creates the storage backend selected by ATTACHMENT_STORAGE
+func New() (Storage, error) {
+	switch backend := config.GetEnvOrDefault("ATTACHMENT_STORAGE", "local"); backend {
+	case "local":
+		return NewLocalStorage(config.GetEnvOrDefault("ATTACHMENT_LOCAL_DIR", "attachments"))
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  config.GetEnv("S3_ENDPOINT"),
+			Region:    config.GetEnv("S3_REGION"),
+			Bucket:    config.GetEnv("S3_BUCKET"),
+			AccessKey: config.GetEnv("S3_ACCESS_KEY"),
+			SecretKey: config.GetEnv("S3_SECRET_KEY"),
+			UseSSL:    config.GetEnvBool("S3_USE_SSL", true),
+			PathStyle: config.GetEnvBool("S3_PATH_STYLE", false),
+		})
+	default:
+		return nil, fmt.Errorf("unknown attachment storage backend %q", backend)
+	}
+}
+
+// cleanKey normalizes a key and rejects anything that is not a relative path inside the storage root
+func cleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if key == "" || path.IsAbs(key) {
+		return "", ErrInvalidKey
+	}
+
+	// "." would address the storage root itself
+	cleaned := path.Clean(key)
+	if cleaned == "." || !filepath.IsLocal(filepath.FromSlash(cleaned)) {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
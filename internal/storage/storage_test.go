@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		err  error
+	}{
+		{key: "report.pdf", want: "report.pdf"},
+		{key: "uploads/1234", want: "uploads/1234"},
+		{key: "invoices/./2024//march.pdf", want: "invoices/2024/march.pdf"},
+		{key: "invoices/../report.pdf", want: "report.pdf"},
+		{key: `invoices\march.pdf`, want: "invoices/march.pdf"},
+		{key: "", err: ErrInvalidKey},
+		{key: ".", err: ErrInvalidKey},
+		{key: "/etc/passwd", err: ErrInvalidKey},
+		{key: "../secret", err: ErrInvalidKey},
+		{key: "invoices/../../secret", err: ErrInvalidKey},
+		{key: `..\secret`, err: ErrInvalidKey},
+		{key: `\etc\passwd`, err: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanKey(tt.key)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("cleanKey(%q) error = %v, want %v", tt.key, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cleanKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -43,10 +43,19 @@ func init() {
 
 	// Initialize services
 	services.InitMailService()
+	if err := services.InitAttachmentStorage(); err != nil {
+		log.Fatalf("failed to initialize attachment storage: %v", err)
+	}
+	if err := services.InitBrand(); err != nil {
+		log.Fatalf("failed to load brand variables: %v", err)
+	}
 }
 
 func setupApp() *fiber.App {
-	app := fiber.New(fiber.Config{})
+	app := fiber.New(fiber.Config{
+		// Stream request bodies so attachment uploads are not buffered in memory
+		StreamRequestBody: true,
+	})
 
 	// Middleware
 	app.Use(helmet.New())
@@ -80,6 +89,12 @@ func main() {
 	var app *fiber.App
 	var consumer *queue.Consumer
 
+	// Clear compiled templates when files in templates/ change
+	services.StartTemplateWatcher()
+
+	// Start the delivery worker pool used by the REST API and for recovering queued mails
+	services.StartDeliveryWorkers()
+
 	// Setup Fiber app only if REST API is enabled
 	if enableRestAPI {
 		app = setupApp()
@@ -110,8 +125,11 @@ func main() {
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
 
 	go func() {
+		defer close(stopped)
+
 		<-quit
 		log.Println("Gracefully shutting down...")
 
@@ -129,8 +147,10 @@ func main() {
 			}
 		}
 
+		// Wait for in-flight deliveries, remaining queued mails are recovered on next start
+		services.StopDeliveryWorkers()
+
 		log.Println("Server gracefully stopped")
-		os.Exit(0)
 	}()
 
 	// Start server only if REST API is enabled
@@ -141,7 +161,8 @@ func main() {
 		}
 	} else {
 		log.Println("REST API is disabled, running in consumer-only mode")
-		// Keep the main goroutine alive for the consumer
-		select {}
 	}
+
+	// Keep the main goroutine alive until shutdown has finished
+	<-stopped
 }